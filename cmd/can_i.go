@@ -0,0 +1,181 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/spf13/cobra"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// permission is a verb on a resource in a namespace.
+type permission struct {
+	Verb      string
+	Resource  string
+	Namespace string
+}
+
+// accessReview is the answer of the cluster to whether a permission is granted.
+type accessReview struct {
+	permission
+	Allowed bool
+	Reason  string
+}
+
+// tbacVerbs are the verbs tbac uses on secrets.
+var tbacVerbs = []string{"get", "list", "create", "patch", "delete"}
+
+// canICmd represents the can-i command
+var canICmd = &cobra.Command{
+	Use:   "can-i [verb] [resource]",
+	Args:  cobra.RangeArgs(0, 2),
+	Short: "Check what you are allowed to do in your teams namespace",
+	Long: `
+Check what you are allowed to do in your teams namespace. Without arguments,
+all verbs used by tbac are checked.
+
+Examples
+# Show what you are allowed to do with secrets
+kubectl tbac can-i
+
+# Check if you may delete secrets in the sandbox namespace
+kubectl tbac can-i delete secrets --sandbox
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return CanI(cmd.Context(), cli, args)
+	},
+}
+
+// CanI prints whether the user is allowed to perform the verbs tbac uses on
+// secrets, or the given verb on the given resource. A missing permission is
+// returned as an error.
+func CanI(ctx context.Context, rt *Runtime, args []string) error {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	namespace, err := rt.Namespace(ctx)
+	if err != nil {
+		return err
+	}
+
+	var permissions []permission
+	switch len(args) {
+	case 0:
+		for _, verb := range tbacVerbs {
+			permissions = append(permissions, permission{Verb: verb, Resource: "secrets", Namespace: namespace})
+		}
+	case 1:
+		permissions = append(permissions, permission{Verb: args[0], Resource: "secrets", Namespace: namespace})
+	default:
+		permissions = append(permissions, permission{Verb: args[0], Resource: args[1], Namespace: namespace})
+	}
+
+	reviews, err := reviewAccess(ctx, clientSet, permissions...)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		if err := explainDenied(reviews); err != nil {
+			return err
+		}
+		rt.Printer.Dataf("yes\n")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(rt.Printer.Out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "VERB\tRESOURCE\tNAMESPACE\tALLOWED")
+	for _, r := range reviews {
+		allowed := "no"
+		if r.Allowed {
+			allowed = "yes"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", r.Verb, r.Resource, r.Namespace, allowed)
+	}
+	return w.Flush()
+}
+
+// preflight checks that the user has the permissions before a change is made,
+// so that a missing permission is explained instead of failing halfway.
+// If the cluster cannot answer, the change is attempted anyway.
+func preflight(ctx context.Context, rt *Runtime, clientSet kubernetes.Interface, permissions ...permission) error {
+	if !rt.Preflight {
+		return nil
+	}
+	reviews, err := reviewAccess(ctx, clientSet, permissions...)
+	if err != nil {
+		rt.Printer.Debugf("Skipping permission check: %v\n", err)
+		return nil
+	}
+	return explainDenied(reviews)
+}
+
+// reviewAccess asks the cluster whether the current user has each permission.
+func reviewAccess(ctx context.Context, clientSet kubernetes.Interface, permissions ...permission) ([]accessReview, error) {
+	reviews := make([]accessReview, 0, len(permissions))
+	for _, p := range permissions {
+		review, err := clientSet.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: p.Namespace,
+					Verb:      p.Verb,
+					Resource:  p.Resource,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to review access to %v %v in namespace %v: %w", p.Verb, p.Resource, p.Namespace, err)
+		}
+		reviews = append(reviews, accessReview{permission: p, Allowed: review.Status.Allowed, Reason: review.Status.Reason})
+	}
+	return reviews, nil
+}
+
+// explainDenied returns a Forbidden error telling which permissions are
+// missing and who to ask for them, or nil if all are allowed.
+func explainDenied(reviews []accessReview) error {
+	var denied []string
+	namespace := ""
+	for _, r := range reviews {
+		if r.Allowed {
+			continue
+		}
+		namespace = r.Namespace
+		line := fmt.Sprintf("- %v %v in namespace %v", r.Verb, r.Resource, r.Namespace)
+		if r.Reason != "" {
+			line += fmt.Sprintf(" (%v)", r.Reason)
+		}
+		denied = append(denied, line)
+	}
+	if len(denied) == 0 {
+		return nil
+	}
+	team := strings.TrimSuffix(namespace, "-sandbox")
+	return tbacerrors.New(tbacerrors.Forbidden,
+		"you are missing permissions:\n%v\nAsk a member of %v to add you to the group sec-tbac-%v, or ask your cluster administrators to grant them",
+		strings.Join(denied, "\n"), team, team)
+}
+
+func init() {
+	rootCmd.AddCommand(canICmd)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// denyVerbs makes the fake cluster deny the given verbs on secrets.
+func denyVerbs(clientSet *fake.Clientset, verbs ...string) {
+	clientSet.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = true
+		for _, verb := range verbs {
+			if review.Spec.ResourceAttributes.Verb == verb {
+				review.Status.Allowed = false
+			}
+		}
+		return true, review, nil
+	})
+}
+
+func TestPreflightDenied(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(sandboxSecret("my-app-default", "team-a", nil))
+	denyVerbs(clientSet, "delete")
+	rt := NewRuntime(clientSet, "team-a")
+	rt.Preflight = true
+
+	err := DeleteSecret(context.Background(), rt, &DeleteSecretOptions{Name: "my-app-default"})
+	assert.True(t, tbacerrors.Is(err, tbacerrors.Forbidden))
+	assert.Contains(t, err.Error(), "delete secrets in namespace team-a")
+	assert.Contains(t, err.Error(), "sec-tbac-team-a")
+	_, err = clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "my-app-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	// Patching without removing keys does not need delete.
+	err = PatchSecret(context.Background(), rt, &PatchSecretOptions{Name: "my-app-default", Data: []string{"KEY=value"}})
+	assert.Nil(t, err)
+	err = PatchSecret(context.Background(), rt, &PatchSecretOptions{Name: "my-app-default", RemoveData: []string{"KEY"}})
+	assert.True(t, tbacerrors.Is(err, tbacerrors.Forbidden))
+}
+
+func TestCanI(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset()
+	denyVerbs(clientSet, "delete")
+	rt := NewRuntime(clientSet, "team-a")
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+
+	assert.Nil(t, CanI(context.Background(), rt, nil))
+	assert.Contains(t, out.String(), "create   secrets    team-a      yes")
+	assert.Contains(t, out.String(), "delete   secrets    team-a      no")
+
+	out.Reset()
+	assert.Nil(t, CanI(context.Background(), rt, []string{"create", "secrets"}))
+	assert.Equal(t, "yes\n", out.String())
+
+	err := CanI(context.Background(), rt, []string{"delete"})
+	assert.True(t, tbacerrors.Is(err, tbacerrors.Forbidden))
+}
@@ -0,0 +1,152 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+)
+
+// CheckCertsOptions holds the input of check certs.
+type CheckCertsOptions struct {
+	// Threshold is the number of days certificates must at least be valid for.
+	Threshold int
+}
+
+// storedCertificate is a certificate found in a key of a secret.
+type storedCertificate struct {
+	Secret string
+	Key    string
+	*tbac.Certificate
+}
+
+var checkCertsOpts = &CheckCertsOptions{}
+
+// checkCertsCmd represents the check certs command
+var checkCertsCmd = &cobra.Command{
+	Use:     "certs",
+	Aliases: []string{"cert", "certificates"},
+	Args:    cobra.NoArgs,
+	Short:   "Report when certificates stored in secrets expire",
+	Long: `
+Report when the certificates stored in the secrets of your teams namespace
+expire. Certificates are read from TLS secrets and from keys ending in .crt or
+.pem. The command fails if a certificate expires within the threshold, so it
+can be used in CI.
+
+Examples
+# Report certificates and fail if any expires within 30 days
+kubectl tbac check certs
+
+# Fail if any certificate expires within 60 days
+kubectl tbac check certs --threshold 60
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return CheckCerts(cmd.Context(), cli, checkCertsOpts)
+	},
+}
+
+// CheckCerts prints the certificates in the secrets of the namespace, soonest
+// expiring first. An Expiring error is returned if any certificate expires
+// within the threshold.
+func CheckCerts(ctx context.Context, rt *Runtime, o *CheckCertsOptions) error {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	namespace, err := rt.Namespace(ctx)
+	if err != nil {
+		return err
+	}
+	secrets, err := rt.client(clientSet).ListSecrets(ctx, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to list secrets in namespace %v: %w", namespace, err)
+	}
+
+	var certs []storedCertificate
+	for _, s := range secrets {
+		for key, value := range s.Data {
+			if !holdsCertificates(s, key) {
+				continue
+			}
+			found, err := tbac.ParseCertificates(value)
+			if err != nil {
+				rt.Printer.Warnf("Warning: skipping %v in secret/%v: %v\n", key, s.Name, err)
+				continue
+			}
+			for _, c := range found {
+				certs = append(certs, storedCertificate{Secret: s.Name, Key: key, Certificate: c})
+			}
+		}
+	}
+	if len(certs) == 0 {
+		rt.Printer.Warnf("No certificates found.\n")
+		return nil
+	}
+	sort.SliceStable(certs, func(i, j int) bool {
+		return certs[i].NotAfter.Before(certs[j].NotAfter)
+	})
+
+	threshold := time.Duration(o.Threshold) * 24 * time.Hour
+	expiring := 0
+	w := tabwriter.NewWriter(rt.Printer.Out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "SECRET\tKEY\tSUBJECT\tEXPIRES\tDAYS LEFT")
+	for _, c := range certs {
+		expiresIn := c.ExpiresIn(rt.Now())
+		if expiresIn < threshold {
+			expiring++
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", c.Secret, c.Key, c.Subject, c.NotAfter.Format(time.RFC3339), daysLeft(expiresIn))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if expiring > 0 {
+		return tbacerrors.New(tbacerrors.Expiring, "%v of %v certificates in namespace %v expire within %v days", expiring, len(certs), namespace, o.Threshold)
+	}
+	return nil
+}
+
+// holdsCertificates reports whether a key of a secret is expected to hold
+// PEM encoded certificates.
+func holdsCertificates(s tbac.Secret, key string) bool {
+	if s.Type == v1.SecretTypeTLS && key == v1.TLSCertKey {
+		return true
+	}
+	return strings.HasSuffix(key, ".crt") || strings.HasSuffix(key, ".pem")
+}
+
+// daysLeft returns d in whole days, rounded down, or "expired".
+func daysLeft(d time.Duration) string {
+	if d <= 0 {
+		return "expired"
+	}
+	return fmt.Sprintf("%v", days(d))
+}
+
+func init() {
+	checkCmd.AddCommand(checkCertsCmd)
+	checkCertsCmd.Flags().IntVarP(&checkCertsOpts.Threshold, "threshold", "", days(certificateExpiryWarning), "Fail if a certificate expires within this many days")
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCheckCerts(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "kubectl-tbac")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	soonCert, _ := writeCertificate(t, dir, "soon.example.com", now.Add(10*24*time.Hour))
+	laterCert, _ := writeCertificate(t, dir, "later.example.com", now.Add(100*24*time.Hour))
+	soon, err := ioutil.ReadFile(soonCert)
+	assert.Nil(t, err)
+	later, err := ioutil.ReadFile(laterCert)
+	assert.Nil(t, err)
+
+	tlsSecret := sandboxSecret("web-default", "team-a", map[string][]byte{v1.TLSCertKey: soon})
+	tlsSecret.Type = v1.SecretTypeTLS
+	clientSet := fake.NewSimpleClientset(
+		tlsSecret,
+		sandboxSecret("ca-default", "team-a", map[string][]byte{"ca.pem": later, "PASSWORD": []byte("bar")}),
+		sandboxSecret("broken-default", "team-a", map[string][]byte{"client.crt": []byte("-----BEGIN CERTIFICATE-----\nZ2FyYmFnZQ==\n-----END CERTIFICATE-----\n")}),
+	)
+	rt := NewRuntime(clientSet, "team-a")
+	rt.Now = func() time.Time { return now }
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: errOut}
+
+	err = CheckCerts(context.Background(), rt, &CheckCertsOptions{Threshold: 30})
+	assert.Equal(t, tbacerrors.Expiring, tbacerrors.KindOf(err))
+	assert.Contains(t, err.Error(), "1 of 2 certificates")
+	assert.Equal(t, `SECRET        KEY       SUBJECT                EXPIRES                DAYS LEFT
+web-default   tls.crt   CN=soon.example.com    2020-05-11T12:00:00Z   10
+ca-default    ca.pem    CN=later.example.com   2020-08-09T12:00:00Z   100
+`, out.String())
+	assert.Contains(t, errOut.String(), "skipping client.crt in secret/broken-default")
+
+	assert.Nil(t, CheckCerts(context.Background(), rt, &CheckCertsOptions{Threshold: 5}))
+}
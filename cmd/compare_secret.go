@@ -0,0 +1,172 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/spf13/cobra"
+)
+
+// SecretComparison holds the differences between the secrets in the team
+// namespace and its sandbox namespace.
+type SecretComparison struct {
+	OnlyInTeam    []string
+	OnlyInSandbox []string
+	Differing     map[string][]KeyDifference
+}
+
+// KeyDifference describes one data key that differs between two secrets.
+type KeyDifference struct {
+	Key    string
+	Reason string
+}
+
+// compareSecretCmd represents the compare secrets command
+var compareSecretCmd = &cobra.Command{
+	Use:     "secret",
+	Aliases: secretAliases,
+	Args:    cobra.NoArgs,
+	Short:   "Compare secrets in the team namespace and its sandbox",
+	Long: `
+Compare secrets in your teams namespace with the ones in its sandbox namespace.
+Lists secrets that only exist in one of them and, for secrets in both, which keys
+differ. Values are compared by their hashes and are never printed.
+
+Examples
+# Compare secrets in your team namespace and sandbox
+kubectl tbac compare secrets
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		clientSet, err := cli.ClientSet()
+		if err != nil {
+			return fmt.Errorf("failed to create clientSet: %w", err)
+		}
+		team, sandbox, err := cli.teamAndSandbox(cmd.Context())
+		if err != nil {
+			return err
+		}
+		comparison, err := CompareSecrets(cmd.Context(), cli.client(clientSet), team, sandbox)
+		if err != nil {
+			return err
+		}
+		comparison.PrettyPrint(cli.Printer.Out, team, sandbox)
+		return nil
+	},
+}
+
+// CompareSecrets compares the secrets in the team namespace with the ones in
+// the sandbox namespace.
+func CompareSecrets(ctx context.Context, client *tbac.Client, team, sandbox string) (*SecretComparison, error) {
+	teamSecrets, err := listSecrets(ctx, client, team)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets in namespace %v: %w", team, err)
+	}
+	sandboxSecrets, err := listSecrets(ctx, client, sandbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets in namespace %v: %w", sandbox, err)
+	}
+
+	inSandbox := make(map[string]bool)
+	for _, name := range sandboxSecrets {
+		inSandbox[name] = true
+	}
+
+	comparison := &SecretComparison{Differing: make(map[string][]KeyDifference)}
+	for _, name := range teamSecrets {
+		if !inSandbox[name] {
+			comparison.OnlyInTeam = append(comparison.OnlyInTeam, name)
+			continue
+		}
+		delete(inSandbox, name)
+
+		teamDesc, err := describeSecret(ctx, client, team, name)
+		if err != nil {
+			return nil, err
+		}
+		sandboxDesc, err := describeSecret(ctx, client, sandbox, name)
+		if err != nil {
+			return nil, err
+		}
+		if diff := compareData(teamDesc.Data, sandboxDesc.Data, team, sandbox); len(diff) > 0 {
+			comparison.Differing[name] = diff
+		}
+	}
+	for name := range inSandbox {
+		comparison.OnlyInSandbox = append(comparison.OnlyInSandbox, name)
+	}
+	sort.Strings(comparison.OnlyInTeam)
+	sort.Strings(comparison.OnlyInSandbox)
+	return comparison, nil
+}
+
+// compareData returns the keys that are missing in one of a and b or whose
+// values have different hashes.
+func compareData(a, b map[string][]byte, aName, bName string) (diff []KeyDifference) {
+	for k, v := range a {
+		w, ok := b[k]
+		if !ok {
+			diff = append(diff, KeyDifference{Key: k, Reason: "only in " + aName})
+			continue
+		}
+		aSum, bSum := sha256.Sum256(v), sha256.Sum256(w)
+		if !bytes.Equal(aSum[:], bSum[:]) {
+			diff = append(diff, KeyDifference{Key: k, Reason: "value differs"})
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			diff = append(diff, KeyDifference{Key: k, Reason: "only in " + bName})
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Key < diff[j].Key })
+	return diff
+}
+
+// PrettyPrint prints the comparison without revealing any values.
+func (c *SecretComparison) PrettyPrint(w io.Writer, team, sandbox string) {
+	if len(c.OnlyInTeam) == 0 && len(c.OnlyInSandbox) == 0 && len(c.Differing) == 0 {
+		fmt.Fprintf(w, "Secrets in %v and %v are identical.\n", team, sandbox)
+		return
+	}
+	for _, name := range c.OnlyInTeam {
+		fmt.Fprintf(w, " * %v: only in %v\n", name, team)
+	}
+	for _, name := range c.OnlyInSandbox {
+		fmt.Fprintf(w, " * %v: only in %v\n", name, sandbox)
+	}
+	var names []string
+	for name := range c.Differing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, " * %v: keys differ\n", name)
+		for _, d := range c.Differing[name] {
+			fmt.Fprintf(w, "     - %v (%v)\n", d.Key, d.Reason)
+		}
+	}
+}
+
+func init() {
+	compareCmd.AddCommand(compareSecretCmd)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCompareSecrets(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(
+		sandboxSecret("only-team", "team-a", nil),
+		sandboxSecret("only-sandbox", "team-a-sandbox", nil),
+		sandboxSecret("same", "team-a", map[string][]byte{"KEY": []byte("value")}),
+		sandboxSecret("same", "team-a-sandbox", map[string][]byte{"KEY": []byte("value")}),
+		sandboxSecret("drifted", "team-a", map[string][]byte{
+			"SAME":      []byte("value"),
+			"CHANGED":   []byte("prod"),
+			"TEAM_ONLY": []byte("value"),
+		}),
+		sandboxSecret("drifted", "team-a-sandbox", map[string][]byte{
+			"SAME":         []byte("value"),
+			"CHANGED":      []byte("sandbox"),
+			"SANDBOX_ONLY": []byte("value"),
+		}),
+	)
+
+	comparison, err := CompareSecrets(context.Background(), tbac.NewClient(clientSet), "team-a", "team-a-sandbox")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"only-team"}, comparison.OnlyInTeam)
+	assert.Equal(t, []string{"only-sandbox"}, comparison.OnlyInSandbox)
+	assert.NotContains(t, comparison.Differing, "same")
+	assert.Equal(t, []KeyDifference{
+		{Key: "CHANGED", Reason: "value differs"},
+		{Key: "SANDBOX_ONLY", Reason: "only in team-a-sandbox"},
+		{Key: "TEAM_ONLY", Reason: "only in team-a"},
+	}, comparison.Differing["drifted"])
+}
@@ -16,18 +16,30 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"time"
 
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
 	"github.com/Bisnode/kubectl-tbac/util"
 	"github.com/spf13/cobra"
-	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
-var container string
-var app string
+// CreateSecretOptions holds the input of create secret.
+type CreateSecretOptions struct {
+	Name      string
+	Container string
+	App       string
+	Data      []string
+	// RotateEvery is how often the keys must be rotated, such as "90d".
+	RotateEvery string
+	// TTL is how long the secret lives before gc deletes it, such as "24h".
+	TTL string
+	GenerateOptions
+	RestartOptions
+}
+
+var createSecretOpts = &CreateSecretOptions{}
 
 // secretCmd represents the secret command
 var createSecretCmd = &cobra.Command{
@@ -49,59 +61,81 @@ kubectl tbac create secret my-secret --namespace team-platform -d "USER=foo" -d
 
 # Create a secret for a sidecar named opa
 kubectl tbac create secret my-secret --container opa -d "USER=foo" -d "PWD=bar"
+
+# Create a secret with a random 32 character password and a 16 byte hex salt
+kubectl tbac create secret my-secret -d "USER=foo" --generate PWD --generate SALT:16:hex
+
+# Create a secret whose password must be rotated every 90 days
+kubectl tbac create secret my-secret -d "USER=foo" --generate PWD --rotate-every 90d
+
+# Create a temporary secret for debugging that expires after 24 hours
+kubectl tbac create secret debug-creds -d "TOKEN=foo" --ttl 24h
+
+# Create a secret and restart the deployments of app my-app to pick it up
+kubectl tbac create secret my-secret --app my-app -d "USER=foo" --restart --wait
 `,
 
-	Run: func(cmd *cobra.Command, args []string) {
-		clientSet, err := util.CreateClientSet(&Context)
-		if err != nil {
-			fmt.Printf("Failed to create clientSet: %v\n", err)
-			os.Exit(1)
-		}
-		if err := CreateSecret(clientSet, &args[0], &container, data); err != nil {
-			fmt.Println(err)
-		}
+	RunE: func(cmd *cobra.Command, args []string) error {
+		createSecretOpts.Name = args[0]
+		return CreateSecret(cmd.Context(), cli, createSecretOpts)
 	},
 }
 
 // CreateSecret creates a secret in teams namespace
-func CreateSecret(clientSet kubernetes.Interface, secretName, container *string, data []string) (err error) {
-	secretsClient := clientSet.CoreV1().Secrets(Namespace)
-	appLabel := *secretName
-
-	if app != "" {
-		appLabel = app
+func CreateSecret(ctx context.Context, rt *Runtime, o *CreateSecretOptions) (err error) {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
 	}
-
-	newSecret := &v1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      *secretName + "-" + *container,
-			Namespace: Namespace,
-			Labels: map[string]string{
-				"app":                        appLabel,
-				"tbac.bisnode.com/container": *container,
-				"tbac.bisnode.com/sandbox":   fmt.Sprintf("%v", sandbox),
-			},
-			Annotations: map[string]string{
-				"tbac.bisnode.com/last-modified": fmt.Sprintf("%v", metav1.Now().Rfc3339Copy()),
-				"tbac.bisnode.com/time-created":  fmt.Sprintf("%v", metav1.Now().Rfc3339Copy()),
-			},
-		},
-		Data: util.AssembleInputData(data),
+	namespace, err := rt.Namespace(ctx)
+	if err != nil {
+		return err
 	}
-
-	newSecret, err = secretsClient.Create(newSecret)
+	if err := preflight(ctx, rt, clientSet, permission{Verb: "create", Resource: "secrets", Namespace: namespace}); err != nil {
+		return err
+	}
+	rotationPeriod, err := parseRotationPeriod(o.RotateEvery)
+	if err != nil {
+		return err
+	}
+	ttl, err := parseTTL(o.TTL)
 	if err != nil {
-		fmt.Printf("Error creating resource: %v\n", err.Error())
 		return err
 	}
+	data := util.AssembleInputData(o.Data)
+	generated, err := generateData(o.GenerateOptions, data)
+	if err != nil {
+		return err
+	}
+	client := rt.client(clientSet)
+	newSecret, err := client.CreateSecret(ctx, namespace, tbac.CreateSecretOptions{
+		Name:           o.Name,
+		Container:      o.Container,
+		App:            o.App,
+		RotationPeriod: rotationPeriod,
+		TTL:            ttl,
+		Generated:      generated,
+		Data:           data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create secret in namespace %v: %w", namespace, err)
+	}
 
-	fmt.Printf("Created secret/%v in namespace %v\n", newSecret.Name, Namespace)
-	return
+	rt.Printer.Infof("Created secret/%v in namespace %v\n", newSecret.Name, namespace)
+	if expires, ok := newSecret.ExpiresAt(); ok {
+		rt.Printer.Infof("It expires at %v and is then deleted by kubectl tbac gc\n", expires.Format(time.RFC3339))
+	}
+	printGenerated(rt, generated, data, o.ShowGenerated)
+	return restartWorkloads(ctx, rt, client, newSecret, o.RestartOptions)
 }
 
 func init() {
 	createCmd.AddCommand(createSecretCmd)
-	createSecretCmd.Flags().StringArrayVarP(&data, "data", "d", []string{}, "Data to add to secret")
-	createSecretCmd.Flags().StringVarP(&container, "container", "c", "default", "Which container to create secret for. Only set this if you want to create a secret for a sidecar. (Default: \"default\"")
-	createSecretCmd.Flags().StringVarP(&app, "app", "a", "", "Set the app label different than the secret name. Note that the app label must match the app label on the service that should use this secret.")
+	createSecretCmd.Flags().StringArrayVarP(&createSecretOpts.Data, "data", "d", []string{}, "Data to add to secret")
+	createSecretCmd.Flags().StringVarP(&createSecretOpts.Container, "container", "c", "default", "Which container to create secret for. Only set this if you want to create a secret for a sidecar. (Default: \"default\"")
+	createSecretCmd.Flags().StringVarP(&createSecretOpts.App, "app", "a", "", "Set the app label different than the secret name. Note that the app label must match the app label on the service that should use this secret.")
+	createSecretCmd.Flags().StringVarP(&createSecretOpts.RotateEvery, "rotate-every", "", "", "How often the keys must be rotated, such as 90d. Overdue keys are listed by get secrets --rotation-due")
+	createSecretCmd.Flags().StringVarP(&createSecretOpts.TTL, "ttl", "", "", "Let the secret expire after this long, such as 24h or 7d. Expired secrets are deleted by kubectl tbac gc")
+	addGenerateFlags(createSecretCmd, &createSecretOpts.GenerateOptions)
+	addRestartFlags(createSecretCmd, &createSecretOpts.RestartOptions)
 }
@@ -0,0 +1,137 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/spf13/cobra"
+)
+
+// CreateDockerRegistrySecretOptions holds the input of create secret docker-registry.
+type CreateDockerRegistrySecretOptions struct {
+	Name                 string
+	Container            string
+	App                  string
+	Server               string
+	Username             string
+	PasswordStdin        bool
+	AttachServiceAccount bool
+}
+
+var createDockerRegistrySecretOpts = &CreateDockerRegistrySecretOptions{}
+
+// createDockerRegistrySecretCmd represents the create secret docker-registry command
+var createDockerRegistrySecretCmd = &cobra.Command{
+	Use:   "docker-registry [name] --server server --username username --password-stdin",
+	Args:  cobra.ExactArgs(1),
+	Short: "Create a secret for pulling images from a private registry",
+	Long: `
+Create a kubernetes.io/dockerconfigjson secret in your teams namespace, for
+pulling images from a private registry. The password is read from stdin so
+that it is not kept in your shell history.
+
+Examples
+# Create a pull secret for ghcr.io
+echo "$TOKEN" | kubectl tbac create secret docker-registry my-registry --server ghcr.io --username foo --password-stdin
+
+# Create a pull secret and use it for all pods running as the default service account
+echo "$TOKEN" | kubectl tbac create secret docker-registry my-registry --server ghcr.io --username foo --password-stdin --attach-service-account
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		createDockerRegistrySecretOpts.Name = args[0]
+		return CreateDockerRegistrySecret(cmd.Context(), cli, createDockerRegistrySecretOpts)
+	},
+}
+
+// CreateDockerRegistrySecret creates a docker registry secret in teams namespace,
+// and optionally attaches it to the default service account.
+func CreateDockerRegistrySecret(ctx context.Context, rt *Runtime, o *CreateDockerRegistrySecretOptions) error {
+	if !o.PasswordStdin {
+		return fmt.Errorf("--password-stdin is required, so that the password is not kept in your shell history")
+	}
+	password, err := ioutil.ReadAll(rt.In)
+	if err != nil {
+		return fmt.Errorf("failed to read password from stdin: %w", err)
+	}
+
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	namespace, err := rt.Namespace(ctx)
+	if err != nil {
+		return err
+	}
+	permissions := []permission{{Verb: "create", Resource: "secrets", Namespace: namespace}}
+	if o.AttachServiceAccount {
+		permissions = append(permissions,
+			permission{Verb: "get", Resource: "serviceaccounts", Namespace: namespace},
+			permission{Verb: "update", Resource: "serviceaccounts", Namespace: namespace})
+	}
+	if err := preflight(ctx, rt, clientSet, permissions...); err != nil {
+		return err
+	}
+
+	client := rt.client(clientSet)
+	if o.AttachServiceAccount {
+		// Checked first, so that a missing service account does not leave
+		// behind a secret nothing uses.
+		if _, err := client.GetServiceAccount(ctx, namespace, tbac.DefaultServiceAccount); err != nil {
+			return fmt.Errorf("cannot attach secret to serviceaccount/%v in namespace %v: %w", tbac.DefaultServiceAccount, namespace, err)
+		}
+	}
+	newSecret, err := client.CreateDockerRegistrySecret(ctx, namespace, tbac.CreateDockerRegistrySecretOptions{
+		Name:      o.Name,
+		Container: o.Container,
+		App:       o.App,
+		Server:    o.Server,
+		Username:  o.Username,
+		Password:  strings.TrimRight(string(password), "\r\n"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create secret in namespace %v: %w", namespace, err)
+	}
+	rt.Printer.Infof("Created secret/%v in namespace %v\n", newSecret.Name, namespace)
+
+	if !o.AttachServiceAccount {
+		return nil
+	}
+	attached, err := client.AttachImagePullSecret(ctx, namespace, tbac.DefaultServiceAccount, newSecret.Name)
+	if err != nil {
+		return fmt.Errorf("secret/%v was created, but attaching it to serviceaccount/%v failed: %w", newSecret.Name, tbac.DefaultServiceAccount, err)
+	}
+	if attached {
+		rt.Printer.Infof("Attached secret/%v to the imagePullSecrets of serviceaccount/%v\n", newSecret.Name, tbac.DefaultServiceAccount)
+	}
+	return nil
+}
+
+func init() {
+	createSecretCmd.AddCommand(createDockerRegistrySecretCmd)
+	createDockerRegistrySecretCmd.Flags().StringVarP(&createDockerRegistrySecretOpts.Server, "server", "", "", "Registry to pull images from, for example ghcr.io")
+	createDockerRegistrySecretCmd.Flags().StringVarP(&createDockerRegistrySecretOpts.Username, "username", "u", "", "Username for the registry")
+	createDockerRegistrySecretCmd.Flags().BoolVarP(&createDockerRegistrySecretOpts.PasswordStdin, "password-stdin", "", false, "Read the password for the registry from stdin")
+	createDockerRegistrySecretCmd.Flags().BoolVarP(&createDockerRegistrySecretOpts.AttachServiceAccount, "attach-service-account", "", false, "Add the secret to the imagePullSecrets of the default service account of the namespace")
+	createDockerRegistrySecretCmd.Flags().StringVarP(&createDockerRegistrySecretOpts.Container, "container", "c", "default", "Which container to create secret for. Only set this if you want to create a secret for a sidecar.")
+	createDockerRegistrySecretCmd.Flags().StringVarP(&createDockerRegistrySecretOpts.App, "app", "a", "", "Set the app label different than the secret name. Note that the app label must match the app label on the service that should use this secret.")
+	_ = createDockerRegistrySecretCmd.MarkFlagRequired("server")
+	_ = createDockerRegistrySecretCmd.MarkFlagRequired("username")
+}
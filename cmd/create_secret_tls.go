@@ -0,0 +1,129 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/spf13/cobra"
+)
+
+// certificateExpiryWarning is how long before expiry certificates are warned about.
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+// CreateTLSSecretOptions holds the input of create secret tls.
+type CreateTLSSecretOptions struct {
+	Name      string
+	Container string
+	App       string
+	CertFile  string
+	KeyFile   string
+}
+
+var createTLSSecretOpts = &CreateTLSSecretOptions{}
+
+// createTLSSecretCmd represents the create secret tls command
+var createTLSSecretCmd = &cobra.Command{
+	Use:   "tls [name] --cert file --key file",
+	Args:  cobra.ExactArgs(1),
+	Short: "Create a TLS secret in your teams namespace",
+	Long: `
+Create a kubernetes.io/tls secret in your teams namespace. The certificate and
+key must be PEM encoded, and the key must belong to the certificate. You are
+warned if the certificate expires within 30 days.
+
+Examples
+# Create a TLS secret from a certificate and its key
+kubectl tbac create secret tls my-cert --cert tls.crt --key tls.key
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		createTLSSecretOpts.Name = args[0]
+		return CreateTLSSecret(cmd.Context(), cli, createTLSSecretOpts)
+	},
+}
+
+// CreateTLSSecret creates a TLS secret in teams namespace
+func CreateTLSSecret(ctx context.Context, rt *Runtime, o *CreateTLSSecretOptions) error {
+	cert, err := ioutil.ReadFile(o.CertFile)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+	key, err := ioutil.ReadFile(o.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+	// Validate before asking the cluster anything, so mistakes are reported first.
+	certificate, err := tbac.ValidateTLS(cert, key)
+	if err != nil {
+		return err
+	}
+
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	namespace, err := rt.Namespace(ctx)
+	if err != nil {
+		return err
+	}
+	if err := preflight(ctx, rt, clientSet, permission{Verb: "create", Resource: "secrets", Namespace: namespace}); err != nil {
+		return err
+	}
+	newSecret, err := rt.client(clientSet).CreateTLSSecret(ctx, namespace, tbac.CreateTLSSecretOptions{
+		Name:      o.Name,
+		Container: o.Container,
+		App:       o.App,
+		Cert:      cert,
+		Key:       key,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create secret in namespace %v: %w", namespace, err)
+	}
+
+	rt.Printer.Infof("Created secret/%v in namespace %v\n", newSecret.Name, namespace)
+	warnExpiry(rt, newSecret.Name, certificate)
+	return nil
+}
+
+// warnExpiry warns if a certificate has expired or expires soon.
+func warnExpiry(rt *Runtime, name string, certificate *tbac.Certificate) {
+	expiresIn := certificate.ExpiresIn(rt.Now())
+	switch {
+	case expiresIn <= 0:
+		rt.Printer.Warnf("Warning: the certificate of secret/%v expired at %v\n", name, certificate.NotAfter.Format(time.RFC3339))
+	case expiresIn < certificateExpiryWarning:
+		rt.Printer.Warnf("Warning: the certificate of secret/%v expires in %v days, at %v\n", name, days(expiresIn), certificate.NotAfter.Format(time.RFC3339))
+	}
+}
+
+// days returns d in whole days, rounded down.
+func days(d time.Duration) int {
+	return int(d / (24 * time.Hour))
+}
+
+func init() {
+	createSecretCmd.AddCommand(createTLSSecretCmd)
+	createTLSSecretCmd.Flags().StringVarP(&createTLSSecretOpts.CertFile, "cert", "", "", "Path to the PEM encoded certificate")
+	createTLSSecretCmd.Flags().StringVarP(&createTLSSecretOpts.KeyFile, "key", "", "", "Path to the PEM encoded private key")
+	createTLSSecretCmd.Flags().StringVarP(&createTLSSecretOpts.Container, "container", "c", "default", "Which container to create secret for. Only set this if you want to create a secret for a sidecar.")
+	createTLSSecretCmd.Flags().StringVarP(&createTLSSecretOpts.App, "app", "a", "", "Set the app label different than the secret name. Note that the app label must match the app label on the service that should use this secret.")
+	_ = createTLSSecretCmd.MarkFlagRequired("cert")
+	_ = createTLSSecretCmd.MarkFlagRequired("key")
+}
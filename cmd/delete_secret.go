@@ -16,15 +16,19 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
 
-	"github.com/Bisnode/kubectl-tbac/util"
 	"github.com/spf13/cobra"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
+// DeleteSecretOptions holds the input of delete secret.
+type DeleteSecretOptions struct {
+	Name string
+}
+
+var deleteSecretOpts = &DeleteSecretOptions{}
+
 // deleteSecretCmd represents the deleteSecret command
 var deleteSecretCmd = &cobra.Command{
 	Use:   "secret [name]",
@@ -42,28 +46,30 @@ kubectl tbac delete secret my-secret"
 # Create a secret using namespace
 kubectl tbac delete secret my-secret --namespace team-platform"
 `,
-	Run: func(cmd *cobra.Command, args []string) {
-		clientSet, err := util.CreateClientSet(&Context)
-		if err != nil {
-			fmt.Printf("Failed to create clientSet: %v\n", err)
-			os.Exit(1)
-		}
-		err = DeleteSecret(clientSet, args[0])
-		if err != nil {
-			fmt.Printf("Failed to delete secret: %v\n", err)
-			os.Exit(1)
-		}
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deleteSecretOpts.Name = args[0]
+		return DeleteSecret(cmd.Context(), cli, deleteSecretOpts)
 	},
 }
 
 // DeleteSecret deletes a secret based on secret name
-func DeleteSecret(clientSet kubernetes.Interface, secretName string) (err error) {
+func DeleteSecret(ctx context.Context, rt *Runtime, o *DeleteSecretOptions) (err error) {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	namespace, err := rt.Namespace(ctx)
+	if err != nil {
+		return err
+	}
+	if err := preflight(ctx, rt, clientSet, permission{Verb: "delete", Resource: "secrets", Namespace: namespace}); err != nil {
+		return err
+	}
 	// Delete the secret
-	if err := clientSet.CoreV1().Secrets(Namespace).Delete(secretName, &metav1.DeleteOptions{}); err != nil {
-		fmt.Printf("Error deleting resource in namespace %v: %v\n", Namespace, err.Error())
-		return fmt.Errorf(Namespace)
+	if err := rt.client(clientSet).DeleteSecret(ctx, namespace, o.Name); err != nil {
+		return fmt.Errorf("failed to delete secret in namespace %v: %w", namespace, err)
 	}
-	fmt.Printf("Deleted secret/%v in namespace %v\n", secretName, Namespace)
+	rt.Printer.Infof("Deleted secret/%v in namespace %v\n", o.Name, namespace)
 	return nil
 }
 
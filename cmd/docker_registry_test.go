@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateDockerRegistrySecret(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "team-a"}})
+	rt := NewRuntime(clientSet, "team-a")
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+	rt.In = strings.NewReader("s3cret\n")
+	o := &CreateDockerRegistrySecretOptions{Name: "registry", Container: "default", Server: "ghcr.io", Username: "foo"}
+
+	assert.NotNil(t, CreateDockerRegistrySecret(context.Background(), rt, o))
+
+	o.PasswordStdin = true
+	o.AttachServiceAccount = true
+	assert.Nil(t, CreateDockerRegistrySecret(context.Background(), rt, o))
+	assert.Contains(t, out.String(), "Attached secret/registry-default to the imagePullSecrets of serviceaccount/default")
+	secret, err := clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "registry-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Contains(t, string(secret.Data[v1.DockerConfigJsonKey]), `"password":"s3cret"`)
+
+	out.Reset()
+	assert.Nil(t, GetSecret(context.Background(), rt, &GetSecretOptions{}))
+	assert.Equal(t, " * registry-default (docker-registry, ghcr.io)\n", out.String())
+}
+
+func TestCreateDockerRegistrySecretNoServiceAccount(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset()
+	rt := NewRuntime(clientSet, "team-a")
+	rt.In = strings.NewReader("s3cret\n")
+	o := &CreateDockerRegistrySecretOptions{Name: "registry", Container: "default", Server: "ghcr.io", Username: "foo", PasswordStdin: true, AttachServiceAccount: true}
+
+	err := CreateDockerRegistrySecret(context.Background(), rt, o)
+	assert.Equal(t, 5, tbacerrors.ExitCode(err))
+	// Nothing is created when the secret cannot be attached.
+	secrets, err := clientSet.CoreV1().Secrets("team-a").List(context.Background(), metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, secrets.Items)
+}
@@ -0,0 +1,124 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// GCOptions holds the input of gc.
+type GCOptions struct {
+	// DryRun only lists what would be deleted.
+	DryRun bool
+}
+
+var gcOpts = &GCOptions{}
+
+// gcCmd represents the gc command
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Args:  cobra.NoArgs,
+	Short: "Delete expired resources",
+	Long: `
+Delete the tbac resources in your teams namespace that have expired, such as
+secrets created with --ttl. By default nothing is deleted, only listed. Run
+with --dry-run=false to delete.
+
+Examples
+# List expired resources
+kubectl tbac gc
+
+# Delete expired resources
+kubectl tbac gc --dry-run=false
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return GC(cmd.Context(), cli, gcOpts)
+	},
+}
+
+// GC lists the expired secrets in the namespace, and deletes them unless
+// it is a dry run.
+func GC(ctx context.Context, rt *Runtime, o *GCOptions) error {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	namespace, err := rt.Namespace(ctx)
+	if err != nil {
+		return err
+	}
+	client := rt.client(clientSet)
+	expired, err := client.ExpiredSecrets(ctx, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to find expired secrets in namespace %v: %w", namespace, err)
+	}
+	if len(expired) == 0 {
+		rt.Printer.Warnf("No expired resources found.\n")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(rt.Printer.Out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tEXPIRES\tEXPIRED")
+	for _, s := range expired {
+		expires, _ := s.ExpiresAt()
+		fmt.Fprintf(w, "secret/%v\t%v\t%v ago\n", s.Name, expires.Format(time.RFC3339), duration.HumanDuration(rt.Now().Sub(expires)))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if o.DryRun {
+		rt.Printer.Infof("Dry run, nothing was deleted. Run with --dry-run=false to delete %v resources.\n", len(expired))
+		return nil
+	}
+
+	if err := preflight(ctx, rt, clientSet, permission{Verb: "delete", Resource: "secrets", Namespace: namespace}); err != nil {
+		return err
+	}
+	for _, s := range expired {
+		if err := client.DeleteSecret(ctx, namespace, s.Name); err != nil {
+			return fmt.Errorf("failed to delete secret/%v in namespace %v: %w", s.Name, namespace, err)
+		}
+		rt.Printer.Infof("Deleted secret/%v in namespace %v\n", s.Name, namespace)
+	}
+	return nil
+}
+
+// parseTTL parses --ttl. Empty means the resource does not expire.
+func parseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	ttl, err := tbac.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q, it must be positive", s)
+	}
+	return ttl, nil
+}
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+	gcCmd.Flags().BoolVarP(&gcOpts.DryRun, "dry-run", "", true, "Only list the expired resources, without deleting them")
+}
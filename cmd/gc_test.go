@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGC(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset()
+	rt := NewRuntime(clientSet, "team-a")
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+	rt.Now = func() time.Time { return time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC) }
+	assert.Nil(t, CreateSecret(context.Background(), rt, &CreateSecretOptions{Name: "debug", Data: []string{"TOKEN=foo"}, TTL: "24h"}))
+	assert.Nil(t, CreateSecret(context.Background(), rt, &CreateSecretOptions{Name: "app", Data: []string{"TOKEN=bar"}}))
+	assert.Equal(t, "Created secret/debug-default in namespace team-a\n"+
+		"It expires at 2020-05-02T12:00:00Z and is then deleted by kubectl tbac gc\n"+
+		"Created secret/app-default in namespace team-a\n", out.String())
+
+	out.Reset()
+	rt.Now = func() time.Time { return time.Date(2020, 5, 2, 10, 0, 0, 0, time.UTC) }
+	assert.Nil(t, GetSecret(context.Background(), rt, &GetSecretOptions{}))
+	assert.Equal(t, " * app-default\n * debug-default (expires in 120m)\n", out.String())
+
+	out.Reset()
+	rt.Now = func() time.Time { return time.Date(2020, 5, 2, 15, 0, 0, 0, time.UTC) }
+	assert.Nil(t, GC(context.Background(), rt, &GCOptions{DryRun: true}))
+	assert.Equal(t, "NAME                   EXPIRES                EXPIRED\n"+
+		"secret/debug-default   2020-05-02T12:00:00Z   3h ago\n"+
+		"Dry run, nothing was deleted. Run with --dry-run=false to delete 1 resources.\n", out.String())
+	_, err := clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "debug-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	assert.Nil(t, GC(context.Background(), rt, &GCOptions{DryRun: false}))
+	_, err = clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "debug-default", metav1.GetOptions{})
+	assert.Error(t, err)
+	_, err = clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "app-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+}
@@ -0,0 +1,94 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/spf13/cobra"
+)
+
+// GenerateOptions tells which keys of a secret get random values.
+type GenerateOptions struct {
+	// Generate holds KEY[:length[:charset]] specs.
+	Generate []string
+	// ShowGenerated prints the generated values.
+	ShowGenerated bool
+}
+
+// addGenerateFlags adds the flags of GenerateOptions to a command.
+func addGenerateFlags(cmd *cobra.Command, o *GenerateOptions) {
+	cmd.Flags().StringArrayVarP(&o.Generate, "generate", "g", []string{}, "Generate a random value for a key, as KEY[:length[:charset]]. Charset is alphanumeric (default), hex, base64 or uuid")
+	cmd.Flags().BoolVarP(&o.ShowGenerated, "show-generated", "", false, "Print the generated values")
+}
+
+// generateData parses the specs of o and generates their values. Keys must
+// not also be given with --data.
+func generateData(o GenerateOptions, data map[string][]byte) ([]tbac.GenerateSpec, error) {
+	specs, err := parseGenerateSpecs(o.Generate)
+	if err != nil {
+		return nil, err
+	}
+	generated, err := tbac.GenerateData(specs)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range generated {
+		if _, found := data[k]; found {
+			return nil, fmt.Errorf("key %v is both given with --data and generated", k)
+		}
+		data[k] = v
+	}
+	return specs, nil
+}
+
+// parseGenerateSpecs parses KEY[:length[:charset]] specs.
+func parseGenerateSpecs(values []string) ([]tbac.GenerateSpec, error) {
+	specs := make([]tbac.GenerateSpec, 0, len(values))
+	for _, v := range values {
+		spec, err := tbac.ParseGenerateSpec(v)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// printGenerated tells which keys were generated. The values themselves are
+// only printed if asked to, so they do not end up in terminal logs.
+func printGenerated(rt *Runtime, specs []tbac.GenerateSpec, data map[string][]byte, show bool) {
+	for _, spec := range specs {
+		if show {
+			rt.Printer.Dataf("%v=%v\n", spec.Key, string(data[spec.Key]))
+			continue
+		}
+		rt.Printer.Infof("Generated %v (%v)\n", spec.Key, describeSpec(spec))
+	}
+}
+
+// describeSpec describes a generated value without revealing it.
+func describeSpec(spec tbac.GenerateSpec) string {
+	switch spec.Charset {
+	case tbac.CharsetUUID:
+		return "uuid"
+	case tbac.CharsetHex, tbac.CharsetBase64:
+		return fmt.Sprintf("%v random bytes, %v", spec.Length, spec.Charset)
+	default:
+		return fmt.Sprintf("%v %v characters", spec.Length, spec.Charset)
+	}
+}
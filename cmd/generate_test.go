@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateSecretGenerate(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset()
+	rt := NewRuntime(clientSet, "default")
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+
+	err := CreateSecret(context.Background(), rt, &CreateSecretOptions{
+		Name:            "my-app",
+		Data:            []string{"USERNAME=foo"},
+		GenerateOptions: GenerateOptions{Generate: []string{"PASSWORD:20", "SALT:4:hex"}},
+	})
+	assert.Nil(t, err)
+	created, err := clientSet.CoreV1().Secrets("default").Get(context.Background(), "my-app-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("foo"), created.Data["USERNAME"])
+	assert.Len(t, created.Data["PASSWORD"], 20)
+	assert.Len(t, created.Data["SALT"], 8)
+	// Generated values are not printed unless asked to.
+	assert.Equal(t, "Created secret/my-app-default in namespace default\n"+
+		"Generated PASSWORD (20 alphanumeric characters)\n"+
+		"Generated SALT (4 random bytes, hex)\n", out.String())
+	assert.NotContains(t, out.String(), string(created.Data["PASSWORD"]))
+}
+
+func TestCreateSecretGenerateConflict(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset()
+	rt := NewRuntime(clientSet, "default")
+
+	err := CreateSecret(context.Background(), rt, &CreateSecretOptions{
+		Name:            "my-app",
+		Data:            []string{"PASSWORD=foo"},
+		GenerateOptions: GenerateOptions{Generate: []string{"PASSWORD"}},
+	})
+	assert.EqualError(t, err, "key PASSWORD is both given with --data and generated")
+	assert.Empty(t, clientSet.Actions())
+}
+
+func TestRotateSecret(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(sandboxSecret("my-app", "team-a", map[string][]byte{"PASSWORD": []byte("old")}))
+	rt := NewRuntime(clientSet, "team-a")
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+
+	err := RotateSecret(context.Background(), rt, &RotateSecretOptions{Name: "my-app", Keys: []string{"PASSWORD::uuid"}, ShowGenerated: true})
+	assert.Nil(t, err)
+	rotated, err := clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "my-app", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, rotated.Data["PASSWORD"], 36)
+	assert.Equal(t, "secret/my-app rotated\nPASSWORD="+string(rotated.Data["PASSWORD"])+"\n", out.String())
+
+	err = RotateSecret(context.Background(), rt, &RotateSecretOptions{Name: "my-app", Keys: []string{"MISSING"}})
+	assert.EqualError(t, err, "failed to rotate secret in namespace team-a: key MISSING not found in secret my-app")
+}
+
+func TestRotateSecretAsCreated(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset()
+	rt := NewRuntime(clientSet, "team-a")
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+	err := CreateSecret(context.Background(), rt, &CreateSecretOptions{
+		Name:            "my-app",
+		GenerateOptions: GenerateOptions{Generate: []string{"TOKEN::uuid"}},
+	})
+	assert.Nil(t, err)
+
+	out.Reset()
+	err = RotateSecret(context.Background(), rt, &RotateSecretOptions{Name: "my-app-default", Keys: []string{"TOKEN"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "secret/my-app-default rotated\nGenerated TOKEN (uuid)\n", out.String())
+	rotated, err := clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "my-app-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, rotated.Data["TOKEN"], 36)
+}
@@ -16,14 +16,20 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"io"
+	"sort"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
-	"github.com/Bisnode/kubectl-tbac/util"
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
 	"github.com/spf13/cobra"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
 )
 
 // SecretDescription holds data needed to describe a secret.
@@ -34,10 +40,36 @@ type SecretDescription struct {
 	LastUpdated       string
 	Service           string
 	Container         string
-	Data              map[string][]byte
+	Type              v1.SecretType
+	// Certificate is set for TLS secrets with a valid certificate.
+	Certificate *tbac.Certificate
+	// Registries is set for docker registry secrets.
+	Registries []string
+	// RotationPeriod is how often the keys must be rotated. Zero means never.
+	RotationPeriod time.Duration
+	// RotationDue holds the keys that are due for rotation.
+	RotationDue []string
+	// Expires is when the secret expires, or empty if it does not.
+	Expires string
+	// UsedBy holds the workloads referencing the secret. It is nil when
+	// they could not be looked up.
+	UsedBy []tbac.Consumer
+	Data   map[string][]byte
 }
 
-var export bool
+// GetSecretOptions holds the input of get secret.
+type GetSecretOptions struct {
+	Name           string
+	Export         bool
+	AllTeams       bool
+	IncludeSandbox bool
+	Orphaned       bool
+	// OlderThan is how long ago orphaned secrets must have been modified, such as "30d".
+	OlderThan   string
+	RotationDue bool
+}
+
+var getSecretOpts = &GetSecretOptions{}
 
 // getSecretCmd represents the getSecret command
 var getSecretCmd = &cobra.Command{
@@ -45,55 +77,181 @@ var getSecretCmd = &cobra.Command{
 	Args:    cobra.RangeArgs(0, 1),
 	Aliases: secretAliases,
 	Short:   "Get a list of secrets or describe one.",
-	Long:    `List secrets in team namespace or describe one.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		clientSet, err := util.CreateClientSet(&Context)
+	Long: `
+List secrets in team namespace or describe one.
+
+Examples
+# List secrets in your team namespace
+kubectl tbac get secrets
+
+# List secrets in the namespaces of all your teams, including their sandboxes
+kubectl tbac get secrets --all-teams --include-sandbox
+
+# List secrets not used by any workload and not modified in 30 days
+kubectl tbac get secrets --orphaned
+
+# List keys not rotated within the rotation period of their secret
+kubectl tbac get secrets --rotation-due
+
+# Describe a secret
+kubectl tbac get secret my-secret-default
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			getSecretOpts.Name = args[0]
+		}
+		if cmd.Flags().Changed("older-than") && !getSecretOpts.Orphaned {
+			return fmt.Errorf("--older-than can only be used with --orphaned")
+		}
+		return GetSecret(cmd.Context(), cli, getSecretOpts)
+	},
+}
+
+// GetSecret lists secrets or describes one, depending on whether a name is given.
+func GetSecret(ctx context.Context, rt *Runtime, o *GetSecretOptions) error {
+	if o.AllTeams && (o.Orphaned || o.RotationDue) {
+		return fmt.Errorf("--all-teams cannot be used with --orphaned or --rotation-due")
+	}
+	if o.AllTeams {
+		if o.Name != "" {
+			return fmt.Errorf("--all-teams cannot be used when describing a secret")
+		}
+		return printAllTeamsSecrets(ctx, rt, o.IncludeSandbox)
+	}
+	if o.Orphaned {
+		if o.Name != "" {
+			return fmt.Errorf("--orphaned cannot be used when describing a secret")
+		}
+		orphaned, _, err := getOrphanedSecrets(ctx, rt, o.OlderThan)
 		if err != nil {
-			fmt.Printf("Failed to create clientSet: %v\n", err)
-			os.Exit(1)
+			return err
 		}
-		if len(args) == 1 {
-			secretDesc, err := GetSecretDescription(clientSet, args[0])
-			if err != nil {
-				fmt.Printf("Failed to get secret %v: %v", args[0], err)
-				os.Exit(1)
-			}
-			if export {
-				secretDesc.ExportSecret()
-				os.Exit(0)
-			}
-			secretDesc.PrettyPrintSecretDesc()
-			os.Exit(0)
+		return printOrphanedSecrets(rt, orphaned)
+	}
+	if o.RotationDue {
+		if o.Name != "" {
+			return fmt.Errorf("--rotation-due cannot be used when describing a secret")
 		}
-		secretList, err := GetSecretList(clientSet)
+		return printRotationDue(ctx, rt)
+	}
+	if o.Name != "" {
+		secretDesc, err := GetSecretDescription(ctx, rt, o.Name)
 		if err != nil {
-			fmt.Printf("Failed to get secrets: %v", err)
-			os.Exit(1)
+			return err
 		}
-		for _, s := range secretList {
-			fmt.Printf(" * %v\n", s)
+		if o.Export {
+			if secretDesc.Type != v1.SecretTypeOpaque {
+				return fmt.Errorf("--export is only supported for Opaque secrets, secret %v is of type %v", o.Name, secretDesc.Type)
+			}
+			secretDesc.ExportSecret(rt.Printer.Out)
+			return nil
 		}
-	},
+		secretDesc.UsedBy = findConsumers(ctx, rt, secretDesc.Namespace, secretDesc.Name)
+		secretDesc.PrettyPrintSecretDesc(rt.Printer.Out)
+		return nil
+	}
+	secretList, err := getSecrets(ctx, rt)
+	if err != nil {
+		return fmt.Errorf("failed to get secrets: %w", err)
+	}
+	for _, s := range secretList {
+		rt.Printer.Dataf(" * %v\n", secretListEntry(s, rt.Now()))
+	}
+	return nil
+}
+
+// secretListEntry returns how a secret is shown in a list, followed by when
+// it expires if it has a TTL.
+func secretListEntry(s tbac.Secret, now time.Time) string {
+	entry := secretTypeEntry(s, now)
+	if expires, ok := s.ExpiresAt(); ok {
+		if left := expires.Sub(now); left > 0 {
+			return fmt.Sprintf("%v (expires in %v)", entry, duration.HumanDuration(left))
+		}
+		return fmt.Sprintf("%v (expired %v ago)", entry, duration.HumanDuration(now.Sub(expires)))
+	}
+	return entry
+}
+
+// secretTypeEntry returns how a secret is shown in a list. TLS secrets show
+// the subject, SANs and expiry of their certificate, and docker registry
+// secrets their registries.
+func secretTypeEntry(s tbac.Secret, now time.Time) string {
+	if s.Type == v1.SecretTypeDockerConfigJson {
+		registries, err := s.Registries()
+		if err != nil {
+			return fmt.Sprintf("%v (docker-registry, invalid docker config)", s.Name)
+		}
+		return fmt.Sprintf("%v (docker-registry, %v)", s.Name, strings.Join(registries, ", "))
+	}
+	if s.Type != v1.SecretTypeTLS {
+		return s.Name
+	}
+	certificate, err := s.Certificate()
+	if err != nil {
+		return fmt.Sprintf("%v (tls, invalid certificate)", s.Name)
+	}
+	expiry := fmt.Sprintf("expires in %v days", days(certificate.ExpiresIn(now)))
+	if certificate.ExpiresIn(now) <= 0 {
+		expiry = fmt.Sprintf("expired %v days ago", days(-certificate.ExpiresIn(now)))
+	}
+	return fmt.Sprintf("%v (tls, %v, SANs: %v, %v)", s.Name, certificate.Subject, strings.Join(certificate.SANs, ", "), expiry)
 }
 
 // PrettyPrintSecretDesc pretty prints a secret as a table view
-func (s *SecretDescription) PrettyPrintSecretDesc() {
-	fmt.Printf("Secret name:%v%v\n", strings.Repeat(" ", 25-len("Secret Name:")), s.Name)
-	fmt.Printf("Service (app label):%v%v\n", strings.Repeat(" ", 25-len("Service (app label):")), s.Service)
-	fmt.Printf("Container:%v%v\n", strings.Repeat(" ", 25-len("Container:")), s.Container)
-	fmt.Printf("Namespace:%v%v\n", strings.Repeat(" ", 25-len("Namespace:")), s.Namespace)
-	fmt.Printf("Created:%v%v\n", strings.Repeat(" ", 25-len("Created:")), s.CreationTimestamp)
-	fmt.Printf("Last updated:%v%v\n\n", strings.Repeat(" ", 25-len("Last updated:")), s.LastUpdated)
+func (s *SecretDescription) PrettyPrintSecretDesc(w io.Writer) {
+	fmt.Fprintf(w, "Secret name:%v%v\n", strings.Repeat(" ", 25-len("Secret Name:")), s.Name)
+	fmt.Fprintf(w, "Service (app label):%v%v\n", strings.Repeat(" ", 25-len("Service (app label):")), s.Service)
+	fmt.Fprintf(w, "Container:%v%v\n", strings.Repeat(" ", 25-len("Container:")), s.Container)
+	fmt.Fprintf(w, "Namespace:%v%v\n", strings.Repeat(" ", 25-len("Namespace:")), s.Namespace)
+	fmt.Fprintf(w, "Created:%v%v\n", strings.Repeat(" ", 25-len("Created:")), s.CreationTimestamp)
+	fmt.Fprintf(w, "Last updated:%v%v\n", strings.Repeat(" ", 25-len("Last updated:")), s.LastUpdated)
+	if s.Type == v1.SecretTypeDockerConfigJson {
+		fmt.Fprintf(w, "Type:%v%v\n", strings.Repeat(" ", 25-len("Type:")), s.Type)
+		fmt.Fprintf(w, "Registries:%v%v\n", strings.Repeat(" ", 25-len("Registries:")), strings.Join(s.Registries, ", "))
+	}
+	if s.Type == v1.SecretTypeTLS {
+		fmt.Fprintf(w, "Type:%v%v\n", strings.Repeat(" ", 25-len("Type:")), s.Type)
+		if s.Certificate == nil {
+			fmt.Fprintf(w, "Certificate:%vinvalid\n", strings.Repeat(" ", 25-len("Certificate:")))
+		} else {
+			fmt.Fprintf(w, "Subject:%v%v\n", strings.Repeat(" ", 25-len("Subject:")), s.Certificate.Subject)
+			fmt.Fprintf(w, "SANs:%v%v\n", strings.Repeat(" ", 25-len("SANs:")), strings.Join(s.Certificate.SANs, ", "))
+			fmt.Fprintf(w, "Expires:%v%v\n", strings.Repeat(" ", 25-len("Expires:")), s.Certificate.NotAfter.Format(time.RFC3339))
+		}
+	}
+	if s.Expires != "" {
+		fmt.Fprintf(w, "Expires (TTL):%v%v\n", strings.Repeat(" ", 25-len("Expires (TTL):")), s.Expires)
+	}
+	if s.RotationPeriod > 0 {
+		fmt.Fprintf(w, "Rotate every:%v%v\n", strings.Repeat(" ", 25-len("Rotate every:")), tbac.FormatPeriod(s.RotationPeriod))
+		if len(s.RotationDue) > 0 {
+			fmt.Fprintf(w, "Rotation due:%v%v\n", strings.Repeat(" ", 25-len("Rotation due:")), strings.Join(s.RotationDue, ", "))
+		}
+	}
+	if s.UsedBy != nil {
+		fmt.Fprintf(w, "Used by:%v", strings.Repeat(" ", 25-len("Used by:")))
+		if len(s.UsedBy) == 0 {
+			fmt.Fprintln(w, "nothing found")
+		}
+		for i, c := range s.UsedBy {
+			if i > 0 {
+				fmt.Fprint(w, strings.Repeat(" ", 25))
+			}
+			fmt.Fprintf(w, "%v/%v (%v)\n", c.Kind, c.Name, strings.Join(c.Via, ", "))
+		}
+	}
+	fmt.Fprintln(w)
 	if len(s.Data) > 0 {
-		fmt.Println(strings.Repeat("-", 25), "DATA", strings.Repeat("-", 25))
+		fmt.Fprintln(w, strings.Repeat("-", 25), "DATA", strings.Repeat("-", 25))
 		for k, v := range s.Data {
-			fmt.Printf("%v=%v\n", k, string(v))
+			fmt.Fprintf(w, "%v=%v\n", k, string(v))
 		}
 	}
 }
 
 // ExportSecret prints out secret in exported format.
-func (s *SecretDescription) ExportSecret() {
+func (s *SecretDescription) ExportSecret(w io.Writer) {
 	// Trim away container from name if found..
 
 	name := strings.TrimSuffix(s.Name, "-"+s.Container)
@@ -104,72 +262,197 @@ func (s *SecretDescription) ExportSecret() {
 	}
 	data := strings.Join(d, " ")
 	out := fmt.Sprintf("kubectl tbac create secret %v --namespace %v %v", name, s.Namespace, data)
-	fmt.Printf("%v\n\n", out)
+	fmt.Fprintf(w, "%v\n\n", out)
 }
 
 // GetSecretList returns a list of secrets in the namespace
-func GetSecretList(clientSet kubernetes.Interface) (secrets []string, err error) {
-	secretList, err := clientSet.
-		CoreV1().
-		Secrets(Namespace).
-		List(metav1.ListOptions{
-			FieldSelector: fmt.Sprintf("type=Opaque"),
-		})
-
+func GetSecretList(ctx context.Context, rt *Runtime) (secrets []string, err error) {
+	secretList, err := getSecrets(ctx, rt)
 	if err != nil {
-		fmt.Printf("Failed to list secrets in namespace %v: %v\n", Namespace, err.Error())
 		return nil, err
 	}
-	for _, s := range secretList.Items {
+	for _, s := range secretList {
 		secrets = append(secrets, s.Name)
 	}
+	return secrets, nil
+}
 
-	if len(secretList.Items) == 0 {
-		fmt.Println("No resources found.")
+// getSecrets returns the secrets in the namespace.
+func getSecrets(ctx context.Context, rt *Runtime) ([]tbac.Secret, error) {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	namespace, err := rt.Namespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := rt.client(clientSet).ListSecrets(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets in namespace %v: %w", namespace, err)
+	}
+
+	if len(secrets) == 0 {
+		rt.Printer.Warnf("No resources found.\n")
 	}
 	return secrets, nil
 }
 
-// GetSecretDescription takes a secret name as input and return it in a SecretDescription.
-func GetSecretDescription(clientSet kubernetes.Interface, secretName string) (secretDesc *SecretDescription, err error) {
-	listOpts := metav1.ListOptions{
-		FieldSelector: fmt.Sprintf("metadata.name=%v", secretName),
+// findConsumers returns the workloads referencing a secret. If they cannot be
+// looked up, for example when listing pods is forbidden, a warning is printed
+// and nil is returned.
+func findConsumers(ctx context.Context, rt *Runtime, namespace, secretName string) []tbac.Consumer {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		rt.Printer.Warnf("Warning: could not find what uses the secret: %v\n", err)
+		return nil
 	}
-	secrets, err := clientSet.
-		CoreV1().
-		Secrets(Namespace).
-		List(listOpts)
+	consumers, err := rt.client(clientSet).SecretConsumers(ctx, namespace, secretName)
+	if err != nil {
+		rt.Printer.Warnf("Warning: could not find what uses the secret: %v\n", err)
+		return nil
+	}
+	// Not nil even when nothing uses the secret, so that this is shown.
+	return append([]tbac.Consumer{}, consumers...)
+}
 
+// GetSecretDescription takes a secret name as input and return it in a SecretDescription.
+func GetSecretDescription(ctx context.Context, rt *Runtime, secretName string) (secretDesc *SecretDescription, err error) {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	namespace, err := rt.Namespace(ctx)
 	if err != nil {
-		fmt.Printf("Failed to list secrets in namespace %v: %v\n", Namespace, err.Error())
 		return nil, err
 	}
+	return describeSecret(ctx, rt.client(clientSet), namespace, secretName)
+}
 
-	// Due to an issue with the ListOptions filters in
-	// kubernetes fake-client that is used for testing
-	// we cannot check for exactly one result
-	if len(secrets.Items) < 1 {
-		err := fmt.Errorf("Secret not found: %v/%v", Namespace, secretName)
+// printAllTeamsSecrets lists secrets in the namespaces of all teams of the user.
+func printAllTeamsSecrets(ctx context.Context, rt *Runtime, includeSandbox bool) error {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	teams, err := rt.Teams(ctx)
+	if err != nil {
+		return err
+	}
+	namespaces := teams
+	if includeSandbox {
+		for _, team := range teams {
+			namespaces = append(namespaces, team+"-sandbox")
+		}
+	}
+
+	secrets, forbidden, err := GetSecretListAllNamespaces(ctx, rt.client(clientSet), namespaces)
+	if err != nil {
+		return err
+	}
+	for _, namespace := range forbidden {
+		rt.Printer.Warnf("Warning: not allowed to list secrets in namespace %v\n", namespace)
+	}
+	if len(secrets) == 0 {
+		rt.Printer.Warnf("No resources found.\n")
+		return nil
+	}
+	w := tabwriter.NewWriter(rt.Printer.Out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME")
+	for _, s := range secrets {
+		fmt.Fprintf(w, "%v\t%v\n", s.Namespace, secretListEntry(s, rt.Now()))
+	}
+	return w.Flush()
+}
+
+// GetSecretListAllNamespaces lists secrets in several namespaces concurrently.
+// Namespaces where listing is forbidden are skipped and returned separately.
+func GetSecretListAllNamespaces(ctx context.Context, client *tbac.Client, namespaces []string) (secrets []tbac.Secret, forbidden []string, err error) {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, namespace := range namespaces {
+		wg.Add(1)
+		go func(namespace string) {
+			defer wg.Done()
+			secretList, err := client.ListSecrets(ctx, namespace)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case tbacerrors.Is(err, tbacerrors.Forbidden):
+				forbidden = append(forbidden, namespace)
+			case err != nil:
+				errs = append(errs, fmt.Errorf("failed to list secrets in namespace %v: %w", namespace, err))
+			default:
+				secrets = append(secrets, secretList...)
+			}
+		}(namespace)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, nil, errs[0]
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		if secrets[i].Namespace != secrets[j].Namespace {
+			return secrets[i].Namespace < secrets[j].Namespace
+		}
+		return secrets[i].Name < secrets[j].Name
+	})
+	sort.Strings(forbidden)
+	return secrets, forbidden, nil
+}
+
+// listSecrets returns the names of the secrets in a namespace.
+func listSecrets(ctx context.Context, client *tbac.Client, namespace string) (secrets []string, err error) {
+	secretList, err := client.ListSecrets(ctx, namespace)
+	if err != nil {
 		return nil, err
 	}
+	for _, s := range secretList {
+		secrets = append(secrets, s.Name)
+	}
+	return secrets, nil
+}
 
-	data := make(map[string][]byte)
-	for k, v := range secrets.Items[0].Data {
-		data[k] = v
+// describeSecret returns a SecretDescription of a secret in a namespace.
+func describeSecret(ctx context.Context, client *tbac.Client, namespace, secretName string) (secretDesc *SecretDescription, err error) {
+	secret, err := client.GetSecret(ctx, namespace, secretName)
+	if tbacerrors.Is(err, tbacerrors.NotFound) {
+		return nil, tbacerrors.New(tbacerrors.NotFound, "secret not found: %v/%v", namespace, secretName)
+	}
+	if err != nil {
+		return nil, err
 	}
+
 	secretDesc = &SecretDescription{
-		Namespace:         Namespace,
+		Namespace:         namespace,
 		Name:              secretName,
-		LastUpdated:       secrets.Items[0].Annotations["tbac.bisnode.com/last-modified"],
-		CreationTimestamp: secrets.Items[0].Annotations["tbac.bisnode.com/time-created"],
-		Service:           secrets.Items[0].Labels["app"],
-		Container:         secrets.Items[0].Labels["tbac.bisnode.com/container"],
-		Data:              data,
+		LastUpdated:       secret.LastModified,
+		CreationTimestamp: secret.Created,
+		Service:           secret.App,
+		Container:         secret.Container,
+		Type:              secret.Type,
+		RotationPeriod:    secret.RotationPeriod,
+		RotationDue:       secret.RotationDue(client.Now()),
+		Expires:           secret.Expires,
+		Data:              secret.Data,
 	}
+	// Invalid certificates and docker configs are shown as such rather than failing the description.
+	secretDesc.Certificate, _ = secret.Certificate()
+	secretDesc.Registries, _ = secret.Registries()
 	return secretDesc, nil
 }
 
 func init() {
 	getCmd.AddCommand(getSecretCmd)
-	getSecretCmd.PersistentFlags().BoolVarP(&export, "export", "", false, "Export as a `kubectl create secret` command")
+	getSecretCmd.PersistentFlags().BoolVarP(&getSecretOpts.Export, "export", "", false, "Export as a `kubectl create secret` command")
+	getSecretCmd.Flags().BoolVarP(&getSecretOpts.AllTeams, "all-teams", "A", false, "List secrets in the namespaces of all your teams")
+	getSecretCmd.Flags().BoolVarP(&getSecretOpts.IncludeSandbox, "include-sandbox", "", false, "Include sandbox namespaces when used with --all-teams")
+	getSecretCmd.Flags().BoolVarP(&getSecretOpts.Orphaned, "orphaned", "", false, "Only list secrets that no workload uses")
+	getSecretCmd.Flags().BoolVarP(&getSecretOpts.RotationDue, "rotation-due", "", false, "Only list keys not rotated within the rotation period of their secret")
+	getSecretCmd.Flags().StringVarP(&getSecretOpts.OlderThan, "older-than", "", defaultOrphanAge, "With --orphaned, only list secrets not modified for this long, such as 30d or 12h")
 }
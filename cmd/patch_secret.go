@@ -16,22 +16,31 @@ limitations under the License.
 package cmd
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"os"
 
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
 	"github.com/Bisnode/kubectl-tbac/util"
 	"github.com/spf13/cobra"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/kubernetes"
 )
 
-var removeData []string
+// PatchSecretOptions holds the input of patch secret.
+type PatchSecretOptions struct {
+	Name       string
+	Data       []string
+	RemoveData []string
+	// RotateEvery sets how often the keys must be rotated, such as "90d".
+	RotateEvery string
+	GenerateOptions
+	RestartOptions
+}
+
+var patchSecretOpts = &PatchSecretOptions{}
 
 // patchSecretCmd represents the patchSecret command
 var patchSecretCmd = &cobra.Command{
-	Use:     "secret [name] [--data key=value|--remove-data key]",
+	Use:     "secret [name] [--data key=value|--remove-data key|--generate key]",
 	Aliases: secretAliases,
 	Args:    cobra.ExactArgs(1),
 	Short:   "Patch a secret in your teams namespace",
@@ -49,104 +58,93 @@ kubectl tbac patch secret my-secret --namespace team-platform -d "USER=foo" -d "
 
 # Remove secret key USERNAME and PASSWORD from secret
 kubectl tbac patch secret my-secret --remove-data USERNAME --remove-data PASSWORD
+
+# Require the keys of a secret to be rotated every 90 days
+kubectl tbac patch secret my-secret --rotate-every 90d
+
+# Add a random uuid to a secret
+kubectl tbac patch secret my-secret --generate CLIENT_ID::uuid
+
+# Patch a secret and wait until the workloads using it are restarted
+kubectl tbac patch secret my-secret -d "PWD=baz" --restart --wait
 `,
-	Run: func(cmd *cobra.Command, args []string) {
-		clientSet, err := util.CreateClientSet(&Context)
-		if err != nil {
-			fmt.Printf("Failed to create clientSet: %v\n", err)
-			os.Exit(1)
-		}
-		if err := PatchSecret(clientSet, &args[0], &removeData, &data); err != nil {
-			fmt.Println(err)
-		}
+	RunE: func(cmd *cobra.Command, args []string) error {
+		patchSecretOpts.Name = args[0]
+		return PatchSecret(cmd.Context(), cli, patchSecretOpts)
 	},
 }
 
 // PatchSecret updates an already existing secret with patched content.
-func PatchSecret(clientSet kubernetes.Interface, secretName *string, removeData, updateData *[]string) (err error) {
-	if len(*removeData) == 0 && len(*updateData) == 0 {
-		return fmt.Errorf("No patch data provided")
+func PatchSecret(ctx context.Context, rt *Runtime, o *PatchSecretOptions) (err error) {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
 	}
-
-	secretsClient := clientSet.CoreV1().Secrets(Namespace)
-
-	originalSecret, err := secretsClient.Get(*secretName, metav1.GetOptions{})
+	namespace, err := rt.Namespace(ctx)
 	if err != nil {
 		return err
 	}
-	patchSecret := originalSecret
-
-	// Resource version cannot be defined in request to Kubernetes.
-	originalSecret.ResourceVersion = ""
-	patchSecret.ResourceVersion = ""
-
-	// If data to remove, remove from the patch version
-	for _, d := range *removeData {
-		if originalSecret.Data[d] != nil {
-			delete(patchSecret.Data, d)
-		}
+	verbs := []string{"get", "patch"}
+	if len(o.RemoveData) > 0 {
+		// Removing keys recreates the secret.
+		verbs = append(verbs, "delete", "create")
 	}
-
-	/*
-		When removing keys in the data of a secret using the --remove-data flag,
-		the secret is first removed from Kubernetes and then recreated
-		without the unwanted keys.
-	*/
-	if len(*removeData) != 0 {
-		if err := secretsClient.Delete(*secretName, &metav1.DeleteOptions{}); err != nil {
-			return err
-		}
-		patchSecret, err = secretsClient.Create(patchSecret)
-		/*If delete was successful but recreate not:
-		- Try to roll back to original secret and abort.
-		- If rollback is unsuccessful then dump data to terminal and abort.*/
-		if err != nil {
-			fmt.Printf("Secret recreation failed: %v\n", err)
-			fmt.Println("Attempt to roll back to original secret")
-			_, err := secretsClient.Create(originalSecret)
-			if err != nil {
-				fmt.Printf("Rollback failed: %v\n", err)
-				fmt.Printf(`The original secret %v was removed and could not be recreated
-					Recreation need to be handled manually. It contained data:`, *secretName)
-				if len(originalSecret.Data) == 0 {
-					fmt.Printf("No data\n")
-				}
-				for k, v := range originalSecret.Data {
-					fmt.Printf("%v:\n%v\n\n", k, string(v))
-				}
-			}
-			return fmt.Errorf("Errors during recreation - Can't continue")
-		}
+	var permissions []permission
+	for _, verb := range verbs {
+		permissions = append(permissions, permission{Verb: verb, Resource: "secrets", Namespace: namespace})
 	}
-
-	if patchSecret.Data == nil {
-		patchSecret.Data = make(map[string][]byte)
-	}
-
-	for k, v := range util.AssembleInputData(*updateData) {
-		patchSecret.Data[k] = v
-	}
-
-	if patchSecret.Annotations == nil {
-		patchSecret.Annotations = make(map[string]string)
+	if err := preflight(ctx, rt, clientSet, permissions...); err != nil {
+		return err
 	}
-
-	patchSecret.Annotations["tbac.bisnode.com/last-modified"] = fmt.Sprintf("%v", metav1.Now().Rfc3339Copy())
-	patch, err := json.Marshal(patchSecret)
+	rotationPeriod, err := parseRotationPeriod(o.RotateEvery)
 	if err != nil {
 		return err
 	}
-
-	_, err = secretsClient.Patch(*secretName, types.StrategicMergePatchType, patch)
+	data := util.AssembleInputData(o.Data)
+	generated, err := generateData(o.GenerateOptions, data)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("secret/%v modified\n", *secretName)
-	return
+	client := rt.client(clientSet)
+	patched, result, err := client.PatchSecret(ctx, namespace, tbac.PatchSecretOptions{
+		Name:           o.Name,
+		Data:           data,
+		RemoveData:     o.RemoveData,
+		RotationPeriod: rotationPeriod,
+		Generated:      generated,
+	})
+	var recreateErr *tbac.RecreateError
+	if errors.As(err, &recreateErr) && recreateErr.RollbackErr != nil {
+		// The secret is gone, so its data is printed for recreating it by hand.
+		rt.Printer.Warnf("Recreation needs to be handled manually. The original secret %v contained data:\n", o.Name)
+		if len(recreateErr.Original.Data) == 0 {
+			rt.Printer.Warnf("No data\n")
+		}
+		for k, v := range recreateErr.Original.Data {
+			rt.Printer.Warnf("%v:\n%v\n\n", k, string(v))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to patch secret in namespace %v: %w", namespace, err)
+	}
+	if !result.Changed() {
+		rt.Printer.Infof("secret/%v unchanged\n", o.Name)
+		return nil
+	}
+	rt.Printer.Infof("secret/%v modified\n", o.Name)
+	printGenerated(rt, generated, data, o.ShowGenerated)
+	if !result.DataChanged {
+		// Workloads do not see annotations, so only new data needs a restart.
+		return nil
+	}
+	return restartWorkloads(ctx, rt, client, patched, o.RestartOptions)
 }
 
 func init() {
 	patchCmd.AddCommand(patchSecretCmd)
-	patchSecretCmd.Flags().StringArrayVarP(&data, "data", "d", []string{}, "Data to add or update in secret")
-	patchSecretCmd.Flags().StringArrayVarP(&removeData, "remove-data", "r", []string{}, "Remove data key from secret")
+	patchSecretCmd.Flags().StringArrayVarP(&patchSecretOpts.Data, "data", "d", []string{}, "Data to add or update in secret")
+	patchSecretCmd.Flags().StringArrayVarP(&patchSecretOpts.RemoveData, "remove-data", "r", []string{}, "Remove data key from secret")
+	patchSecretCmd.Flags().StringVarP(&patchSecretOpts.RotateEvery, "rotate-every", "", "", "Set how often the keys must be rotated, such as 90d")
+	addGenerateFlags(patchSecretCmd, &patchSecretOpts.GenerateOptions)
+	addRestartFlags(patchSecretCmd, &patchSecretOpts.RestartOptions)
 }
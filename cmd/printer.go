@@ -0,0 +1,126 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorGray   = "\033[90m"
+	colorReset  = "\033[0m"
+)
+
+// Printer writes all output of commands. Data goes to Out and is always
+// printed, so that it can be piped. Messages about what happened go to Out
+// unless Quiet is set, while warnings, errors and verbose details go to ErrOut.
+type Printer struct {
+	Out     io.Writer
+	ErrOut  io.Writer
+	Quiet   bool
+	Verbose bool
+	NoColor bool
+}
+
+// NewPrinter returns a Printer writing to stdout and stderr.
+func NewPrinter() *Printer {
+	return &Printer{Out: os.Stdout, ErrOut: os.Stderr}
+}
+
+// Dataf prints data, such as resources and their content.
+func (p *Printer) Dataf(format string, a ...interface{}) {
+	fmt.Fprintf(p.Out, format, a...)
+}
+
+// Infof prints a message about the outcome of a command.
+func (p *Printer) Infof(format string, a ...interface{}) {
+	if p.Quiet {
+		return
+	}
+	fmt.Fprint(p.Out, p.colorize(p.Out, colorGreen, fmt.Sprintf(format, a...)))
+}
+
+// Warnf prints a warning.
+func (p *Printer) Warnf(format string, a ...interface{}) {
+	if p.Quiet {
+		return
+	}
+	fmt.Fprint(p.ErrOut, p.colorize(p.ErrOut, colorYellow, fmt.Sprintf(format, a...)))
+}
+
+// Debugf prints details only shown with --verbose.
+func (p *Printer) Debugf(format string, a ...interface{}) {
+	if !p.Verbose {
+		return
+	}
+	fmt.Fprint(p.ErrOut, p.colorize(p.ErrOut, colorGray, fmt.Sprintf(format, a...)))
+}
+
+// Errorf prints an error. Errors are printed even when Quiet is set.
+func (p *Printer) Errorf(format string, a ...interface{}) {
+	fmt.Fprint(p.ErrOut, p.colorize(p.ErrOut, colorRed, fmt.Sprintf(format, a...)))
+}
+
+// debugTransport prints every API call when verbose output is enabled.
+type debugTransport struct {
+	next    http.RoundTripper
+	printer *Printer
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		t.printer.Debugf("%v %v failed after %v: %v\n", req.Method, req.URL, elapsed, err)
+		return resp, err
+	}
+	t.printer.Debugf("%v %v %v in %v\n", req.Method, req.URL, resp.Status, elapsed)
+	return resp, nil
+}
+
+// CancelRequest cancels req on the wrapped transport, so that client-go can
+// enforce --request-timeout.
+func (t *debugTransport) CancelRequest(req *http.Request) {
+	if canceler, ok := t.next.(interface{ CancelRequest(*http.Request) }); ok {
+		canceler.CancelRequest(req)
+	}
+}
+
+// colorize wraps s in a color code if w is a terminal and colors are enabled.
+// Colors are also disabled by the NO_COLOR environment variable.
+func (p *Printer) colorize(w io.Writer, color, s string) string {
+	if p.NoColor || os.Getenv("NO_COLOR") != "" {
+		return s
+	}
+	f, ok := w.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return s
+	}
+	text := strings.TrimSuffix(s, "\n")
+	return color + text + colorReset + s[len(text):]
+}
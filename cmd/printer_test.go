@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPrinter(t *testing.T) {
+	tests := []struct {
+		name    string
+		printer Printer
+		out     string
+		errOut  string
+	}{
+		{"default", Printer{}, "data\ninfo\n", "warn\nerror\n"},
+		{"quiet", Printer{Quiet: true}, "data\n", "error\n"},
+		{"verbose", Printer{Verbose: true}, "data\ninfo\n", "warn\ndebug\nerror\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+			p := tt.printer
+			p.Out, p.ErrOut = out, errOut
+
+			p.Dataf("data\n")
+			p.Infof("info\n")
+			p.Warnf("warn\n")
+			p.Debugf("debug\n")
+			p.Errorf("error\n")
+			assert.Equal(t, tt.out, out.String())
+			assert.Equal(t, tt.errOut, errOut.String())
+		})
+	}
+}
+
+func TestListOutputStreams(t *testing.T) {
+	t.Parallel()
+	rt := NewRuntime(fake.NewSimpleClientset(), "default")
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: errOut}
+
+	// Data goes to stdout, messages to stderr, so an empty list pipes nothing.
+	assert.Nil(t, GetSecret(context.Background(), rt, &GetSecretOptions{}))
+	assert.Equal(t, "", out.String())
+	assert.Equal(t, "No resources found.\n", errOut.String())
+}
@@ -0,0 +1,142 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/spf13/cobra"
+)
+
+// defaultOrphanAge is how long ago orphaned secrets must have been modified by default.
+const defaultOrphanAge = "30d"
+
+// PruneOptions holds the input of prune.
+type PruneOptions struct {
+	// OlderThan is how long ago secrets must have been modified, such as "30d".
+	OlderThan string
+	// Yes skips the confirmation.
+	Yes bool
+}
+
+var pruneOpts = &PruneOptions{}
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Args:  cobra.NoArgs,
+	Short: "Delete secrets that no workload uses",
+	Long: `
+Delete the tbac secrets in your teams namespace that nothing uses. A secret is
+used if a Deployment, StatefulSet, CronJob, Job, ReplicaSet or Pod references
+it or has its app label, if an Ingress uses it for TLS, or if a ServiceAccount
+lists it. Only secrets not modified for --older-than are deleted.
+
+Examples
+# Show the orphaned secrets and delete them after confirmation
+kubectl tbac prune
+
+# Delete secrets not modified in 90 days without asking
+kubectl tbac prune --older-than 90d --yes
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return Prune(cmd.Context(), cli, pruneOpts)
+	},
+}
+
+// Prune deletes the orphaned secrets in the namespace after confirmation.
+func Prune(ctx context.Context, rt *Runtime, o *PruneOptions) error {
+	orphaned, client, err := getOrphanedSecrets(ctx, rt, o.OlderThan)
+	if err != nil {
+		return err
+	}
+	if err := printOrphanedSecrets(rt, orphaned); err != nil || len(orphaned) == 0 {
+		return err
+	}
+	namespace := orphaned[0].Namespace
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	if err := preflight(ctx, rt, clientSet, permission{Verb: "delete", Resource: "secrets", Namespace: namespace}); err != nil {
+		return err
+	}
+
+	if !o.Yes {
+		ok, err := rt.confirm(ctx, fmt.Sprintf("Delete %v secrets in namespace %v?", len(orphaned), namespace))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			rt.Printer.Infof("Aborted.\n")
+			return nil
+		}
+	}
+	for _, s := range orphaned {
+		if err := client.DeleteSecret(ctx, namespace, s.Name); err != nil {
+			return fmt.Errorf("failed to delete secret/%v in namespace %v: %w", s.Name, namespace, err)
+		}
+		rt.Printer.Infof("Deleted secret/%v in namespace %v\n", s.Name, namespace)
+	}
+	return nil
+}
+
+// getOrphanedSecrets returns the secrets in the namespace that no workload
+// uses and that were not modified for olderThan, together with the client
+// used to find them.
+func getOrphanedSecrets(ctx context.Context, rt *Runtime, olderThan string) ([]tbac.Secret, *tbac.Client, error) {
+	minAge, err := tbac.ParseDuration(olderThan)
+	if err != nil {
+		return nil, nil, err
+	}
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	namespace, err := rt.Namespace(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := rt.client(clientSet)
+	orphaned, err := client.OrphanedSecrets(ctx, namespace, minAge)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find orphaned secrets in namespace %v: %w", namespace, err)
+	}
+	return orphaned, client, nil
+}
+
+// printOrphanedSecrets prints orphaned secrets as a table.
+func printOrphanedSecrets(rt *Runtime, orphaned []tbac.Secret) error {
+	if len(orphaned) == 0 {
+		rt.Printer.Warnf("No orphaned secrets found.\n")
+		return nil
+	}
+	w := tabwriter.NewWriter(rt.Printer.Out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tAPP\tLAST MODIFIED")
+	for _, s := range orphaned {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", s.Name, s.App, s.LastModified)
+	}
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().StringVarP(&pruneOpts.OlderThan, "older-than", "", defaultOrphanAge, "Only delete secrets not modified for this long, such as 30d or 12h")
+	pruneCmd.Flags().BoolVarP(&pruneOpts.Yes, "yes", "y", false, "Do not ask for confirmation")
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	old := sandboxSecret("old-default", "team-a", nil)
+	old.Annotations = map[string]string{tbac.AnnotationLastModified: tbac.Timestamp(now.Add(-60 * 24 * time.Hour))}
+	recent := sandboxSecret("recent-default", "team-a", nil)
+	recent.Annotations = map[string]string{tbac.AnnotationLastModified: tbac.Timestamp(now.Add(-time.Hour))}
+	clientSet := fake.NewSimpleClientset(old, recent)
+	rt := NewRuntime(clientSet, "team-a")
+	rt.Now = func() time.Time { return now }
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+
+	assert.Nil(t, GetSecret(context.Background(), rt, &GetSecretOptions{Orphaned: true, OlderThan: "30d"}))
+	assert.Contains(t, out.String(), "old-default")
+	assert.NotContains(t, out.String(), "recent-default")
+
+	rt.In = strings.NewReader("n\n")
+	assert.Nil(t, Prune(context.Background(), rt, &PruneOptions{OlderThan: "30d"}))
+	_, err := clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "old-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	rt.In = strings.NewReader("y\n")
+	assert.Nil(t, Prune(context.Background(), rt, &PruneOptions{OlderThan: "30d"}))
+	_, err = clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "old-default", metav1.GetOptions{})
+	assert.NotNil(t, err)
+	_, err = clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "recent-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	assert.NotNil(t, Prune(context.Background(), rt, &PruneOptions{OlderThan: "a while"}))
+}
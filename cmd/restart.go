@@ -0,0 +1,85 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/spf13/cobra"
+)
+
+// rolloutPollInterval is how often rollouts are checked while waiting for them.
+var rolloutPollInterval = 2 * time.Second
+
+// RestartOptions tells whether workloads using a secret are restarted after it changes.
+type RestartOptions struct {
+	// Restart restarts the Deployments and StatefulSets with the app label of the secret.
+	Restart bool
+	// Wait waits for the restarted workloads to roll out, for at most WaitTimeout.
+	Wait        bool
+	WaitTimeout time.Duration
+}
+
+// addRestartFlags adds the flags of RestartOptions to a command.
+func addRestartFlags(cmd *cobra.Command, o *RestartOptions) {
+	cmd.Flags().BoolVarP(&o.Restart, "restart", "", false, "Restart the Deployments and StatefulSets with the app label of the secret, so they pick up the change")
+	cmd.Flags().BoolVarP(&o.Wait, "wait", "", false, "With --restart, wait until the restarted workloads are rolled out")
+	cmd.Flags().DurationVarP(&o.WaitTimeout, "wait-timeout", "", 5*time.Minute, "How long to wait for rollouts with --wait")
+}
+
+// restartWorkloads restarts the workloads using a secret that was just changed,
+// if asked to. Errors tell that the secret itself was changed.
+func restartWorkloads(ctx context.Context, rt *Runtime, client *tbac.Client, secret *tbac.Secret, o RestartOptions) error {
+	if !o.Restart {
+		return nil
+	}
+	rollouts, err := client.RestartWorkloads(ctx, secret.Namespace, secret.App, secret.Container)
+	for _, r := range rollouts {
+		if r.OnDelete {
+			rt.Printer.Warnf("Warning: %v/%v uses the OnDelete update strategy, delete its pods to pick up the change\n", strings.ToLower(r.Kind), r.Name)
+			continue
+		}
+		rt.Printer.Infof("Restarted %v/%v\n", strings.ToLower(r.Kind), r.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("secret/%v was changed, but restarting its workloads failed: %w", secret.Name, err)
+	}
+	if len(rollouts) == 0 {
+		rt.Printer.Warnf("Warning: no Deployments or StatefulSets with app label %v to restart\n", secret.App)
+		return nil
+	}
+	if !o.Wait {
+		return nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, o.WaitTimeout)
+	defer cancel()
+	for _, r := range rollouts {
+		if r.OnDelete {
+			continue
+		}
+		rt.Printer.Debugf("Waiting for %v/%v to roll out\n", strings.ToLower(r.Kind), r.Name)
+		if err := client.WaitForRollout(waitCtx, secret.Namespace, r, rolloutPollInterval); err != nil {
+			return fmt.Errorf("secret/%v was changed, but %v/%v did not roll out: %w", secret.Name, strings.ToLower(r.Kind), r.Name, err)
+		}
+		rt.Printer.Infof("%v/%v rolled out\n", strings.ToLower(r.Kind), r.Name)
+	}
+	return nil
+}
@@ -16,30 +16,65 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
 
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
 	"github.com/Bisnode/kubectl-tbac/util"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 const version = "1.0.0"
 
-// Namespace in kubernetes.
-var Namespace string
+// Team sources, telling where the teams of the user come from.
+const (
+	// teamSourceToken reads the teams from the groups of the user.
+	teamSourceToken = "token"
+	// teamSourceRBAC computes the teams from where the user may create secrets.
+	teamSourceRBAC = "rbac"
+)
 
-// Context name
-var Context string
+// globalOptions holds the flags shared by all commands.
+type globalOptions struct {
+	Namespace       string
+	Sandbox         bool
+	IdentitySources []string
+	TeamSource      string
+	TeamSelector    string
+	Lab             bool
+	LabIdentityFile string
+	LabClusterFile  string
 
-var (
-	namespaceFlag string
-	verbose       bool
-	lab           bool
-	sandbox       bool
-	teams         []string
-	data          []string
-)
+	// configFlags holds the standard kubectl flags, such as --kubeconfig and --context.
+	configFlags *genericclioptions.ConfigFlags
+	// whoAmI resolves the teams of the current user. Replaced in tests.
+	whoAmI func(ctx context.Context, matchPrefix, trimPrefix *string, flags *genericclioptions.ConfigFlags, sources []string) ([]string, error)
+	// printer receives verbose details about how the namespace and clientSet are resolved.
+	printer *Printer
+	// namespace is the resolved namespace, cached after the first lookup.
+	namespace string
+	// labClientSet is the fake cluster used in lab mode, saved after each command.
+	labClientSet *fake.Clientset
+}
+
+// globals holds the global flags of this process.
+var globals = &globalOptions{configFlags: newConfigFlags(), whoAmI: util.WhoAmI, printer: NewPrinter()}
+
+// cli is the Runtime used by all commands of this process.
+var cli = globals.runtime()
 
 var secretAliases = []string{
 	"sec",
@@ -50,28 +85,90 @@ var secretAliases = []string{
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "kubectl-tbac",
-	Short: "Simplify managing resources based on tbac.",
+	Use:           "kubectl-tbac",
+	Short:         "Simplify managing resources based on tbac.",
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if cli.Printer.Quiet && cli.Printer.Verbose {
+			return fmt.Errorf("--quiet and --verbose cannot be used together")
+		}
+		// Arguments have been validated at this point, so errors are not about usage.
+		cmd.SilenceUsage = true
+		return nil
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// The process exits with a code based on the kind of error returned.
+// Ctrl-C cancels requests in flight, and a second Ctrl-C exits immediately.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		log.Fatalln(err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	err := execute(ctx)
+	stop()
+	if err != nil {
+		cli.Printer.Errorf("Error: %v\n", err)
+		os.Exit(tbacerrors.ExitCode(err))
+	}
+}
+
+// execute runs the root command. In lab mode the lab cluster is saved even
+// when the command failed, since it may have changed resources before failing.
+func execute(ctx context.Context) error {
+	err := rootCmd.ExecuteContext(ctx)
+	if globals.labClientSet != nil {
+		// Saved even if ctx was canceled, so that the lab cluster stays consistent.
+		saveErr := util.SaveLabCluster(context.WithoutCancel(ctx), globals.labClientSet, globals.LabClusterFile)
+		if err == nil {
+			err = saveErr
+		}
 	}
+	return err
 }
 
 func init() {
 	// Global flags
-	rootCmd.PersistentFlags().BoolVarP(&lab, "lab", "", false, "Run lab to simulate team membership.")
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
-	rootCmd.PersistentFlags().BoolVarP(&sandbox, "sandbox", "s", false, "Set if you want to work in a sandbox Namespace.")
-	rootCmd.PersistentFlags().StringVarP(&namespaceFlag, "namespace", "n", "", "Namespace to create secret in. Usually only needed when member of more than one team.")
-	rootCmd.PersistentFlags().StringVarP(&Context, "context", "", "", "Set context name.")
+	rootCmd.PersistentFlags().BoolVarP(&globals.Lab, "lab", "", false, "Run lab to simulate team membership.")
+	rootCmd.PersistentFlags().StringVarP(&globals.LabIdentityFile, "lab-identity", "", "", "Run lab with the simulated identity (user, teams, expiry) in this YAML file.")
+	rootCmd.PersistentFlags().StringVarP(&globals.LabClusterFile, "lab-cluster", "", "", "Run lab against a fake cluster stored in this file instead of a real cluster.")
+	rootCmd.PersistentFlags().BoolVarP(&cli.Printer.Verbose, "verbose", "v", false, "Verbose output, showing the resolved namespace and context and every API call.")
+	rootCmd.PersistentFlags().BoolVarP(&cli.Printer.Quiet, "quiet", "q", false, "Only print data and errors.")
+	rootCmd.PersistentFlags().BoolVarP(&cli.Printer.NoColor, "no-color", "", false, "Do not color output.")
+	rootCmd.PersistentFlags().IntVarP(&cli.Retry.Retries, "retries", "", tbac.DefaultRetryPolicy.Retries, "How many times to retry requests failing with throttling, timeouts or server errors.")
+	rootCmd.PersistentFlags().DurationVarP(&cli.Retry.Backoff, "retry-backoff", "", tbac.DefaultRetryPolicy.Backoff, "Wait before the first retry. It doubles with every retry, with some randomness added.")
+	rootCmd.PersistentFlags().BoolVarP(&cli.Preflight, "preflight", "", true, "Check permissions before making changes, to explain what is missing.")
+	rootCmd.PersistentFlags().BoolVarP(&globals.Sandbox, "sandbox", "s", false, "Set if you want to work in a sandbox Namespace.")
+	rootCmd.PersistentFlags().StringVarP(&globals.Namespace, "namespace", "n", "", "Namespace to create secret in. Usually only needed when member of more than one team.")
+	rootCmd.PersistentFlags().StringVarP(&globals.TeamSource, "team-source", "", teamSourceToken, "Where your teams come from: token reads your groups, rbac checks in which team namespaces you may create secrets.")
+	rootCmd.PersistentFlags().StringVarP(&globals.TeamSelector, "team-namespace-selector", "", util.DefaultTeamNamespaceSelector, "Label selector of team namespaces, used with --team-source rbac.")
+	rootCmd.PersistentFlags().StringSliceVarP(&globals.IdentitySources, "identity-sources", "", util.DefaultIdentitySources, "Where to find your teams, tried in order: kubectl-login, token, exec and review.")
+
+	// Standard kubectl flags. -s is already used by --sandbox, so --server has no shorthand.
+	kubectlFlags := pflag.NewFlagSet("kubectl", pflag.ExitOnError)
+	globals.configFlags.AddFlags(kubectlFlags)
+	kubectlFlags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Shorthand == "s" {
+			flag.Shorthand = ""
+		}
+		rootCmd.PersistentFlags().AddFlag(flag)
+	})
 
 	// Hide flags
 	_ = rootCmd.PersistentFlags().MarkHidden("lab")
+	_ = rootCmd.PersistentFlags().MarkHidden("lab-identity")
+	_ = rootCmd.PersistentFlags().MarkHidden("lab-cluster")
+}
+
+// newConfigFlags returns the standard kubectl flags, except --namespace which
+// tbac resolves from team membership instead.
+func newConfigFlags() *genericclioptions.ConfigFlags {
+	configFlags := genericclioptions.NewConfigFlags(true)
+	configFlags.Namespace = nil
+	return configFlags
 }
 
 /* ---------------------------------------------
@@ -79,36 +176,144 @@ Custom functions to be used for all commands
 Still not that generic that they fit in utils.
 */
 
-// identifyTeam sets namespace based on team in access token.
+// runtime returns a Runtime that resolves namespace, teams and clientSet from the flags.
+func (g *globalOptions) runtime() *Runtime {
+	rt := NewRuntime(nil, "")
+	rt.Printer = g.printer
+	rt.ClientSet = g.clientSet
+	rt.Namespace = g.identifyTeam
+	rt.Teams = g.resolveTeams
+	rt.Retry.OnRetry = func(request string, attempt int, wait time.Duration, err error) {
+		g.printer.Debugf("%v failed on attempt %v: %v. Retrying in %v\n", request, attempt, err, wait.Round(time.Millisecond))
+	}
+	return rt
+}
+
+// identifyTeam returns the namespace based on team in access token.
 // If sandbox is set, then appending namespace with "-sandbox"
-func identifyTeam() {
+func (g *globalOptions) identifyTeam(ctx context.Context) (string, error) {
+	if g.namespace != "" {
+		return g.namespace, nil
+	}
 
 	// Override namespace if provided with --namespace flag.
-	if namespaceFlag != "" {
-		Namespace = namespaceFlag
-		return
+	if g.Namespace != "" {
+		g.namespace = g.Namespace
+		g.printer.Debugf("Using namespace %v from --namespace\n", g.namespace)
+		return g.namespace, nil
 	}
 
-	if lab {
-		teams = []string{"team-platform"}
+	teams, err := g.resolveTeams(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(teams) > 1 {
+		return "", tbacerrors.New(tbacerrors.AmbiguousTeam,
+			"you are member of multiple teams. Please use --namespace [team-name] to specify which namespace you want to work in.\n- %v",
+			strings.Join(teams, "\n- "))
+	}
+
+	g.namespace = teams[0]
+	if g.Sandbox {
+		g.namespace = g.namespace + "-sandbox"
+	}
+	g.printer.Debugf("Using namespace %v from team membership\n", g.namespace)
+	return g.namespace, nil
+}
+
+// resolveTeams returns the teams of the current user, or of the simulated
+// user in lab mode.
+func (g *globalOptions) resolveTeams(ctx context.Context) ([]string, error) {
+	var teams []string
+	if g.labEnabled() {
+		identity, err := g.labIdentity()
+		if err != nil {
+			return nil, err
+		}
+		teams = identity.Teams
 	} else {
-		matchPrefix := "sec-tbac-team-"
-		trimPrefix := "sec-tbac-"
-		teams = util.WhoAmI(&matchPrefix, &trimPrefix, &Context)
+		var err error
+		switch g.TeamSource {
+		case teamSourceToken:
+			g.printer.Debugf("Finding teams with identity sources %v\n", strings.Join(g.IdentitySources, ", "))
+			matchPrefix := "sec-tbac-team-"
+			trimPrefix := "sec-tbac-"
+			teams, err = g.whoAmI(ctx, &matchPrefix, &trimPrefix, g.configFlags, g.IdentitySources)
+		case teamSourceRBAC:
+			g.printer.Debugf("Finding teams from access to namespaces labelled %v\n", g.TeamSelector)
+			teams, err = g.rbacTeams(ctx)
+		default:
+			err = fmt.Errorf("unknown team source %q, must be %v or %v", g.TeamSource, teamSourceToken, teamSourceRBAC)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if len(teams) == 1 {
-		Namespace = teams[0]
+	if len(teams) == 0 {
+		return nil, tbacerrors.New(tbacerrors.NoTeams, "you are not member of any team. Use --namespace [team-name] to specify which namespace you want to work in")
 	}
+	return teams, nil
+}
 
-	if Namespace == "" && len(teams) > 1 {
-		fmt.Println("You are member of multiple teams. Please use --namespace [team-name] to specify which namespace you want to work in.")
-		for _, team := range teams {
-			fmt.Println("-", team)
+// rbacTeams returns the team namespaces where the user may create secrets.
+func (g *globalOptions) rbacTeams(ctx context.Context) ([]string, error) {
+	clientSet, err := g.clientSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	return util.TeamsFromRBAC(ctx, clientSet, g.TeamSelector)
+}
+
+// labEnabled reports whether lab mode is requested by any of the lab flags.
+func (g *globalOptions) labEnabled() bool {
+	return g.Lab || g.LabIdentityFile != "" || g.LabClusterFile != ""
+}
+
+// labIdentity returns the simulated identity used in lab mode.
+func (g *globalOptions) labIdentity() (*util.LabIdentity, error) {
+	identity := util.DefaultLabIdentity
+	if g.LabIdentityFile != "" {
+		loaded, err := util.LoadLabIdentity(g.LabIdentityFile)
+		if err != nil {
+			return nil, err
 		}
-		os.Exit(1)
+		identity = *loaded
 	}
-	if sandbox {
-		Namespace = Namespace + "-sandbox"
+	if identity.Expired(time.Now()) {
+		return nil, tbacerrors.New(tbacerrors.NoTeams, "lab identity %v expired at %v", identity.User, identity.Expiry)
+	}
+	return &identity, nil
+}
+
+// clientSet returns a clientSet for the current context, or the fake
+// cluster when running lab with --lab-cluster.
+func (g *globalOptions) clientSet() (kubernetes.Interface, error) {
+	if g.LabClusterFile == "" {
+		if ctx, err := util.CurrentContext(g.configFlags); err == nil {
+			g.printer.Debugf("Using context %v\n", ctx)
+		}
+		return util.CreateClientSet(g.configFlags, func(rt http.RoundTripper) http.RoundTripper {
+			return &debugTransport{next: rt, printer: g.printer}
+		})
+	}
+	if g.labClientSet == nil {
+		clientSet, err := util.LoadLabCluster(g.LabClusterFile)
+		if err != nil {
+			return nil, err
+		}
+		g.printer.Debugf("Using lab cluster %v\n", g.LabClusterFile)
+		// Everything is allowed in the lab.
+		clientSet.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			review.Status.Allowed = true
+			return true, review, nil
+		})
+		clientSet.PrependReactor("*", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			g.printer.Debugf("lab: %v %v in namespace %v\n", action.GetVerb(), action.GetResource().Resource, action.GetNamespace())
+			return false, nil, nil
+		})
+		g.labClientSet = clientSet
 	}
+	return g.labClientSet, nil
 }
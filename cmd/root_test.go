@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// fakeWhoAmI returns a whoAmI replacement that resolves to the given teams.
+func fakeWhoAmI(result []string, err error) func(context.Context, *string, *string, *genericclioptions.ConfigFlags, []string) ([]string, error) {
+	return func(context.Context, *string, *string, *genericclioptions.ConfigFlags, []string) ([]string, error) {
+		return result, err
+	}
+}
+
+func TestIdentifyTeam(t *testing.T) {
+	tests := []struct {
+		name      string
+		flag      string
+		sandbox   bool
+		teams     []string
+		err       error
+		namespace string
+		kind      tbacerrors.Kind
+	}{
+		{name: "namespace flag", flag: "team-x", teams: []string{"team-a", "team-b"}, namespace: "team-x"},
+		{name: "single team", teams: []string{"team-a"}, namespace: "team-a"},
+		{name: "single team sandbox", sandbox: true, teams: []string{"team-a"}, namespace: "team-a-sandbox"},
+		{name: "no teams", teams: []string{}, kind: tbacerrors.NoTeams},
+		{name: "multiple teams", teams: []string{"team-a", "team-b"}, kind: tbacerrors.AmbiguousTeam},
+		{name: "no context", err: tbacerrors.New(tbacerrors.NoContext, "no context"), kind: tbacerrors.NoContext},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			g := &globalOptions{Namespace: tt.flag, Sandbox: tt.sandbox, TeamSource: teamSourceToken, whoAmI: fakeWhoAmI(tt.teams, tt.err), printer: &Printer{}}
+
+			namespace, err := g.identifyTeam(context.Background())
+			if tt.kind != tbacerrors.Unknown {
+				assert.True(t, tbacerrors.Is(err, tt.kind), "unexpected error: %v", err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.namespace, namespace)
+		})
+	}
+}
+
+func TestLabIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl-tbac")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "identity.yaml")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("user: jane\nteams: [team-a]\n"), 0600))
+	g := &globalOptions{LabIdentityFile: path, printer: &Printer{}}
+	namespace, err := g.identifyTeam(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "team-a", namespace)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("user: jane\nteams: [team-a]\nexpiry: 2020-01-01T00:00:00Z\n"), 0600))
+	g = &globalOptions{LabIdentityFile: path, printer: &Printer{}}
+	_, err = g.identifyTeam(context.Background())
+	assert.True(t, tbacerrors.Is(err, tbacerrors.NoTeams))
+}
+
+func TestLabCluster(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl-tbac")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cluster.yaml")
+	defer func() {
+		*globals = globalOptions{configFlags: globals.configFlags, whoAmI: globals.whoAmI, printer: globals.printer}
+	}()
+
+	rootCmd.SetArgs([]string{"create", "secret", "lab-secret", "--lab-cluster", path, "-d", "KEY=value"})
+	assert.Nil(t, execute(context.Background()))
+
+	// A new run starts from what the previous run saved.
+	clientSet, err := (&globalOptions{LabClusterFile: path, printer: &Printer{}}).clientSet()
+	assert.Nil(t, err)
+	secret, err := clientSet.CoreV1().Secrets("team-platform").Get(context.Background(), "lab-secret-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), secret.Data["KEY"])
+}
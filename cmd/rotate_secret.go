@@ -0,0 +1,121 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/spf13/cobra"
+)
+
+// RotateSecretOptions holds the input of rotate secret.
+type RotateSecretOptions struct {
+	Name string
+	// Keys holds KEY[:length[:charset]] specs of the keys to regenerate. A
+	// bare KEY is generated the way it was when it was created.
+	Keys          []string
+	ShowGenerated bool
+	RestartOptions
+}
+
+var rotateSecretOpts = &RotateSecretOptions{}
+
+// rotateSecretCmd represents the rotate secret command
+var rotateSecretCmd = &cobra.Command{
+	Use:     "secret [name] [key[:length[:charset]]...]",
+	Aliases: secretAliases,
+	Args:    cobra.MinimumNArgs(2),
+	Short:   "Replace keys of a secret with new random values",
+	Long: `
+Replace keys of a secret in your teams namespace with new cryptographically
+random values. Keys are given as KEY[:length[:charset]], where charset is
+alphanumeric (default), hex, base64 or uuid. A bare KEY that was created with
+--generate is generated the same way again. The new values are only printed
+with --show-generated.
+
+Examples
+# Rotate a generated password of a secret
+kubectl tbac rotate secret my-secret-default PASSWORD
+
+# Rotate a 64 byte hex key and restart the workloads using the secret
+kubectl tbac rotate secret my-secret-default SIGNING_KEY:64:hex --restart
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rotateSecretOpts.Name = args[0]
+		rotateSecretOpts.Keys = args[1:]
+		return RotateSecret(cmd.Context(), cli, rotateSecretOpts)
+	},
+}
+
+// RotateSecret regenerates keys of an existing secret.
+func RotateSecret(ctx context.Context, rt *Runtime, o *RotateSecretOptions) error {
+	specs, err := parseRotateSpecs(o.Keys)
+	if err != nil {
+		return err
+	}
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	namespace, err := rt.Namespace(ctx)
+	if err != nil {
+		return err
+	}
+	if err := preflight(ctx, rt, clientSet,
+		permission{Verb: "get", Resource: "secrets", Namespace: namespace},
+		permission{Verb: "patch", Resource: "secrets", Namespace: namespace},
+	); err != nil {
+		return err
+	}
+	client := rt.client(clientSet)
+	rotated, data, err := client.RotateSecret(ctx, namespace, o.Name, specs)
+	if err != nil {
+		return fmt.Errorf("failed to rotate secret in namespace %v: %w", namespace, err)
+	}
+	rt.Printer.Infof("secret/%v rotated\n", o.Name)
+	for i, spec := range specs {
+		specs[i] = rotated.Generated[spec.Key]
+	}
+	printGenerated(rt, specs, data, o.ShowGenerated)
+	return restartWorkloads(ctx, rt, client, rotated, o.RestartOptions)
+}
+
+// parseRotateSpecs parses KEY[:length[:charset]] specs. A bare KEY gives a
+// spec without length and charset, which tbac.Client.RotateSecret fills in.
+func parseRotateSpecs(values []string) ([]tbac.GenerateSpec, error) {
+	specs := make([]tbac.GenerateSpec, 0, len(values))
+	for _, v := range values {
+		if v != "" && !strings.Contains(v, ":") {
+			specs = append(specs, tbac.GenerateSpec{Key: v})
+			continue
+		}
+		spec, err := tbac.ParseGenerateSpec(v)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+func init() {
+	rotateCmd.AddCommand(rotateSecretCmd)
+	rotateSecretCmd.Flags().BoolVarP(&rotateSecretOpts.ShowGenerated, "show-generated", "", false, "Print the generated values")
+	addRestartFlags(rotateSecretCmd, &rotateSecretOpts.RestartOptions)
+}
@@ -0,0 +1,91 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+)
+
+// overdueKey is a key of a secret that was not rotated within its rotation period.
+type overdueKey struct {
+	Secret string
+	Key    string
+	// LastRotated is zero when it is not known.
+	LastRotated time.Time
+	Overdue     time.Duration
+}
+
+// parseRotationPeriod parses --rotate-every. Empty means no rotation period.
+func parseRotationPeriod(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	period, err := tbac.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if period <= 0 {
+		return 0, fmt.Errorf("invalid rotation period %q, it must be positive", s)
+	}
+	return period, nil
+}
+
+// rotationDue returns the keys of secrets that are due for rotation, in the
+// order of the secrets.
+func rotationDue(secrets []tbac.Secret, now time.Time) []overdueKey {
+	var overdue []overdueKey
+	for i := range secrets {
+		s := &secrets[i]
+		for _, key := range s.RotationDue(now) {
+			o := overdueKey{Secret: s.Name, Key: key}
+			if rotated, err := s.KeyLastRotated(key); err == nil {
+				o.LastRotated = rotated
+				o.Overdue = now.Sub(rotated) - s.RotationPeriod
+			}
+			overdue = append(overdue, o)
+		}
+	}
+	return overdue
+}
+
+// printRotationDue lists the keys of the secrets in the namespace that are
+// due for rotation.
+func printRotationDue(ctx context.Context, rt *Runtime) error {
+	secrets, err := getSecrets(ctx, rt)
+	if err != nil {
+		return fmt.Errorf("failed to get secrets: %w", err)
+	}
+	overdue := rotationDue(secrets, rt.Now())
+	if len(overdue) == 0 {
+		rt.Printer.Warnf("No secrets are due for rotation.\n")
+		return nil
+	}
+	w := tabwriter.NewWriter(rt.Printer.Out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "SECRET\tKEY\tLAST ROTATED\tDAYS OVERDUE")
+	for _, o := range overdue {
+		if o.LastRotated.IsZero() {
+			fmt.Fprintf(w, "%v\t%v\tunknown\tunknown\n", o.Secret, o.Key)
+			continue
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", o.Secret, o.Key, o.LastRotated.Format(time.RFC3339), days(o.Overdue))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetSecretsRotationDue(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset()
+	rt := NewRuntime(clientSet, "team-a")
+	rt.Printer = &Printer{Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}}
+	rt.Now = func() time.Time { return time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC) }
+	for _, o := range []*CreateSecretOptions{
+		{Name: "db", Data: []string{"USER=foo", "PASSWORD=bar"}, RotateEvery: "90d"},
+		{Name: "api", Data: []string{"TOKEN=baz"}},
+	} {
+		assert.Nil(t, CreateSecret(context.Background(), rt, o))
+	}
+	rt.Now = func() time.Time { return time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC) }
+	assert.Nil(t, PatchSecret(context.Background(), rt, &PatchSecretOptions{Name: "db-default", Data: []string{"PASSWORD=new"}}))
+
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+	rt.Now = func() time.Time { return time.Date(2020, 8, 10, 12, 0, 0, 0, time.UTC) }
+	err := GetSecret(context.Background(), rt, &GetSecretOptions{RotationDue: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "SECRET       KEY    LAST ROTATED           DAYS OVERDUE\n"+
+		"db-default   USER   2020-05-01T12:00:00Z   11\n", out.String())
+
+	err = CreateSecret(context.Background(), rt, &CreateSecretOptions{Name: "bad", Data: []string{"A=b"}, RotateEvery: "often"})
+	assert.Error(t, err)
+}
+
+func TestPatchSecretRotateEveryOnly(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(sandboxSecret("my-app", "team-a", map[string][]byte{"PASSWORD": []byte("bar")}))
+	rt := NewRuntime(clientSet, "team-a")
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+
+	err := PatchSecret(context.Background(), rt, &PatchSecretOptions{Name: "my-app", RotateEvery: "90d"})
+	assert.Nil(t, err)
+	assert.Equal(t, "secret/my-app modified\n", out.String())
+	patched, err := clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "my-app", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "90d", patched.Annotations["tbac.bisnode.com/rotation-period"])
+	assert.Equal(t, []byte("bar"), patched.Data["PASSWORD"])
+}
+
+func TestPatchSecretRotateEveryOnlyRestart(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(
+		sandboxSecret("my-app", "team-a", map[string][]byte{"PASSWORD": []byte("bar")}),
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "team-a", Labels: map[string]string{"app": "my-app"}}},
+	)
+	rt := NewRuntime(clientSet, "team-a")
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+
+	// Only the rotation period changes, which workloads do not see.
+	err := PatchSecret(context.Background(), rt, &PatchSecretOptions{
+		Name:           "my-app",
+		RotateEvery:    "90d",
+		RestartOptions: RestartOptions{Restart: true},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "secret/my-app modified\n", out.String())
+	for _, action := range clientSet.Actions() {
+		assert.NotEqual(t, "deployments", action.GetResource().Resource)
+	}
+}
@@ -0,0 +1,102 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Runtime holds everything a command needs from its environment.
+// Commands only read state through a Runtime, so several of them can run
+// side by side, for example in tests or when used as a library.
+type Runtime struct {
+	// ClientSet returns the clientSet used to talk to Kubernetes.
+	ClientSet func() (kubernetes.Interface, error)
+	// Namespace returns the namespace to work in.
+	Namespace func(ctx context.Context) (string, error)
+	// Teams returns the teams the user is member of.
+	Teams func(ctx context.Context) ([]string, error)
+	// Now returns the current time.
+	Now func() time.Time
+	// In is where answers to questions are read from.
+	In io.Reader
+	// Printer writes all output.
+	Printer *Printer
+	// Preflight checks permissions before changes are made.
+	Preflight bool
+	// Retry tells how requests failing with transient errors are retried.
+	Retry tbac.RetryPolicy
+}
+
+// NewRuntime returns a Runtime working in a fixed namespace with a given clientSet.
+func NewRuntime(clientSet kubernetes.Interface, namespace string) *Runtime {
+	return &Runtime{
+		ClientSet: func() (kubernetes.Interface, error) { return clientSet, nil },
+		Namespace: func(context.Context) (string, error) { return namespace, nil },
+		Teams:     func(context.Context) ([]string, error) { return []string{namespace}, nil },
+		Now:       time.Now,
+		In:        os.Stdin,
+		Printer:   NewPrinter(),
+		Retry:     tbac.DefaultRetryPolicy,
+	}
+}
+
+// client returns a tbac client for clientSet, using the clock and retry policy of rt.
+func (rt *Runtime) client(clientSet kubernetes.Interface) *tbac.Client {
+	client := tbac.NewClient(clientSet)
+	client.Now = rt.Now
+	client.Retry = rt.Retry
+	return client
+}
+
+// confirm asks a yes/no question and reports whether it was answered with yes.
+// It gives up waiting for an answer when ctx is canceled.
+func (rt *Runtime) confirm(ctx context.Context, question string) (bool, error) {
+	fmt.Fprintf(rt.Printer.ErrOut, "%v [y/N]: ", question)
+	answers := make(chan string, 1)
+	go func() {
+		answer, _ := bufio.NewReader(rt.In).ReadString('\n')
+		answers <- answer
+	}()
+	select {
+	case <-ctx.Done():
+		fmt.Fprintln(rt.Printer.ErrOut)
+		return false, ctx.Err()
+	case answer := <-answers:
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes", nil
+	}
+}
+
+// teamAndSandbox returns the team namespace and its sandbox namespace,
+// regardless of whether --sandbox is given.
+func (rt *Runtime) teamAndSandbox(ctx context.Context) (team, sandbox string, err error) {
+	namespace, err := rt.Namespace(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	team = strings.TrimSuffix(namespace, "-sandbox")
+	return team, team + "-sandbox", nil
+}
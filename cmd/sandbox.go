@@ -0,0 +1,290 @@
+/*Package cmd ...
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/spf13/cobra"
+)
+
+// SandboxOptions holds the input of the sandbox commands.
+type SandboxOptions struct {
+	// Yes skips the confirmation of sandbox reset.
+	Yes bool
+	// Overwrite replaces existing secrets in the team namespace on promote.
+	Overwrite bool
+}
+
+var sandboxOpts = &SandboxOptions{}
+
+// sandboxCmd represents the sandbox command
+var sandboxCmd = &cobra.Command{
+	Use:   "sandbox",
+	Short: "Manage the sandbox namespace of your team",
+	Long: `
+Inspect, reset and promote resources in the sandbox namespace of your team.
+
+Examples
+# Show a summary of the sandbox
+kubectl tbac sandbox status
+
+# List tbac resources in the sandbox
+kubectl tbac sandbox list
+
+# Delete all tbac resources in the sandbox
+kubectl tbac sandbox reset
+
+# Copy secrets from the sandbox to the team namespace
+kubectl tbac sandbox promote my-secret-default other-secret-default
+`,
+}
+
+var sandboxStatusCmd = &cobra.Command{
+	Use:   "status",
+	Args:  cobra.NoArgs,
+	Short: "Show a summary of the sandbox namespace",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return SandboxStatus(cmd.Context(), cli)
+	},
+}
+
+var sandboxListCmd = &cobra.Command{
+	Use:   "list",
+	Args:  cobra.NoArgs,
+	Short: "List tbac resources in the sandbox namespace",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return SandboxList(cmd.Context(), cli)
+	},
+}
+
+var sandboxResetCmd = &cobra.Command{
+	Use:   "reset",
+	Args:  cobra.NoArgs,
+	Short: "Delete all tbac resources in the sandbox namespace",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return SandboxReset(cmd.Context(), cli, sandboxOpts)
+	},
+}
+
+var sandboxPromoteCmd = &cobra.Command{
+	Use:   "promote [secret-name...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Copy secrets from the sandbox namespace to the team namespace",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return SandboxPromote(cmd.Context(), cli, sandboxOpts, args)
+	},
+}
+
+// SandboxStatus prints a summary of the sandbox namespace.
+func SandboxStatus(ctx context.Context, rt *Runtime) error {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	team, sandbox, err := rt.teamAndSandbox(ctx)
+	if err != nil {
+		return err
+	}
+	secrets, err := ListSandboxSecrets(ctx, rt.client(clientSet), sandbox)
+	if err != nil {
+		return err
+	}
+	lastModified := "-"
+	for _, s := range secrets {
+		if s.LastModified > lastModified {
+			lastModified = s.LastModified
+		}
+	}
+	rt.Printer.Dataf("Sandbox namespace:%v%v\n", strings.Repeat(" ", 25-len("Sandbox namespace:")), sandbox)
+	rt.Printer.Dataf("Team namespace:%v%v\n", strings.Repeat(" ", 25-len("Team namespace:")), team)
+	rt.Printer.Dataf("Secrets:%v%v\n", strings.Repeat(" ", 25-len("Secrets:")), len(secrets))
+	rt.Printer.Dataf("Last modified:%v%v\n", strings.Repeat(" ", 25-len("Last modified:")), lastModified)
+	return nil
+}
+
+// SandboxList prints the tbac resources in the sandbox namespace.
+func SandboxList(ctx context.Context, rt *Runtime) error {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	_, sandbox, err := rt.teamAndSandbox(ctx)
+	if err != nil {
+		return err
+	}
+	secrets, err := ListSandboxSecrets(ctx, rt.client(clientSet), sandbox)
+	if err != nil {
+		return err
+	}
+	if len(secrets) == 0 {
+		rt.Printer.Warnf("No resources found in namespace %v.\n", sandbox)
+		return nil
+	}
+	w := tabwriter.NewWriter(rt.Printer.Out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tAPP\tLAST MODIFIED")
+	for _, s := range secrets {
+		fmt.Fprintf(w, "secret\t%v\t%v\t%v\n", s.Name, s.App, s.LastModified)
+	}
+	return w.Flush()
+}
+
+// SandboxReset deletes all tbac resources in the sandbox namespace after confirmation.
+func SandboxReset(ctx context.Context, rt *Runtime, o *SandboxOptions) error {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	_, sandbox, err := rt.teamAndSandbox(ctx)
+	if err != nil {
+		return err
+	}
+	if err := preflight(ctx, rt, clientSet, permission{Verb: "delete", Resource: "secrets", Namespace: sandbox}); err != nil {
+		return err
+	}
+	if !o.Yes {
+		ok, err := rt.confirm(ctx, fmt.Sprintf("Delete all tbac resources in namespace %v?", sandbox))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			rt.Printer.Infof("Aborted.\n")
+			return nil
+		}
+	}
+	deleted, err := ResetSandbox(ctx, rt.client(clientSet), sandbox)
+	for _, name := range deleted {
+		rt.Printer.Infof("Deleted secret/%v in namespace %v\n", name, sandbox)
+	}
+	return err
+}
+
+// SandboxPromote copies the named secrets from the sandbox namespace to the team namespace.
+func SandboxPromote(ctx context.Context, rt *Runtime, o *SandboxOptions, secretNames []string) error {
+	clientSet, err := rt.ClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create clientSet: %w", err)
+	}
+	team, sandbox, err := rt.teamAndSandbox(ctx)
+	if err != nil {
+		return err
+	}
+	permissions := []permission{
+		{Verb: "get", Resource: "secrets", Namespace: sandbox},
+		{Verb: "create", Resource: "secrets", Namespace: team},
+	}
+	if o.Overwrite {
+		permissions = append(permissions, permission{Verb: "update", Resource: "secrets", Namespace: team})
+	}
+	if err := preflight(ctx, rt, clientSet, permissions...); err != nil {
+		return err
+	}
+	client := rt.client(clientSet)
+	for _, name := range secretNames {
+		if err := PromoteSecret(ctx, client, sandbox, team, name, o.Overwrite); err != nil {
+			return fmt.Errorf("failed to promote secret/%v: %w", name, err)
+		}
+		rt.Printer.Infof("Promoted secret/%v from namespace %v to %v\n", name, sandbox, team)
+	}
+	return nil
+}
+
+// ListSandboxSecrets returns the tbac secrets in the sandbox namespace.
+func ListSandboxSecrets(ctx context.Context, client *tbac.Client, namespace string) ([]tbac.Secret, error) {
+	secrets, err := client.ListSecrets(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var managed []tbac.Secret
+	for _, s := range secrets {
+		// Only secrets created by tbac have a container label.
+		if s.Container != "" {
+			managed = append(managed, s)
+		}
+	}
+	return managed, nil
+}
+
+// ResetSandbox deletes every tbac secret in the sandbox namespace and returns
+// the names of the deleted secrets.
+func ResetSandbox(ctx context.Context, client *tbac.Client, namespace string) (deleted []string, err error) {
+	secrets, err := ListSandboxSecrets(ctx, client, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range secrets {
+		if err := client.DeleteSecret(ctx, namespace, s.Name); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, s.Name)
+	}
+	return deleted, nil
+}
+
+// PromoteSecret copies a secret from the sandbox namespace to the team
+// namespace, where it is created as a secret that is not in the sandbox.
+func PromoteSecret(ctx context.Context, client *tbac.Client, from, to, secretName string, overwrite bool) error {
+	original, err := client.GetSecret(ctx, from, secretName)
+	if err != nil {
+		return err
+	}
+	if original.Container == "" || !strings.HasSuffix(original.Name, "-"+original.Container) {
+		return fmt.Errorf("secret/%v is not managed by tbac", secretName)
+	}
+	// A temporary secret expires at the same time in the team namespace.
+	var ttl time.Duration
+	if expires, ok := original.ExpiresAt(); ok {
+		if ttl = expires.Sub(client.Now()); ttl <= 0 {
+			return fmt.Errorf("secret/%v has expired", secretName)
+		}
+	}
+	generated := make([]tbac.GenerateSpec, 0, len(original.Generated))
+	for _, spec := range original.Generated {
+		generated = append(generated, spec)
+	}
+
+	_, err = client.CreateSecret(ctx, to, tbac.CreateSecretOptions{
+		Name:           strings.TrimSuffix(original.Name, "-"+original.Container),
+		Container:      original.Container,
+		App:            original.App,
+		Type:           original.Type,
+		RotationPeriod: original.RotationPeriod,
+		TTL:            ttl,
+		Overwrite:      overwrite,
+		Generated:      generated,
+		Data:           original.Data,
+	})
+	if tbacerrors.Is(err, tbacerrors.Conflict) {
+		return tbacerrors.New(tbacerrors.Conflict, "secret already exists in namespace %v, use --overwrite to replace it", to)
+	}
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(sandboxCmd)
+	sandboxCmd.AddCommand(sandboxStatusCmd)
+	sandboxCmd.AddCommand(sandboxListCmd)
+	sandboxCmd.AddCommand(sandboxResetCmd)
+	sandboxCmd.AddCommand(sandboxPromoteCmd)
+	sandboxResetCmd.Flags().BoolVarP(&sandboxOpts.Yes, "yes", "y", false, "Do not ask for confirmation")
+	sandboxPromoteCmd.Flags().BoolVarP(&sandboxOpts.Overwrite, "overwrite", "", false, "Replace secrets that already exist in the team namespace")
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// sandboxSecret returns a tbac secret in the given namespace.
+func sandboxSecret(name, namespace string, data map[string][]byte) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app":                        name,
+				"tbac.bisnode.com/container": "default",
+				"tbac.bisnode.com/sandbox":   "true",
+			},
+		},
+		Data: data,
+	}
+}
+
+func TestResetSandbox(t *testing.T) {
+	t.Parallel()
+	unmanaged := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: "team-a-sandbox"}}
+	clientSet := fake.NewSimpleClientset(
+		sandboxSecret("one", "team-a-sandbox", nil),
+		sandboxSecret("two", "team-a-sandbox", nil),
+		sandboxSecret("prod", "team-a", nil),
+		unmanaged,
+	)
+
+	deleted, err := ResetSandbox(context.Background(), tbac.NewClient(clientSet), "team-a-sandbox")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"one", "two"}, deleted)
+
+	remaining, err := clientSet.CoreV1().Secrets("team-a-sandbox").List(context.Background(), metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, remaining.Items, 1)
+	assert.Equal(t, "unmanaged", remaining.Items[0].Name)
+
+	_, err = clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "prod", metav1.GetOptions{})
+	assert.Nil(t, err)
+}
+
+func TestPromoteSecret(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(
+		sandboxSecret("new-default", "team-a-sandbox", map[string][]byte{"KEY": []byte("sandbox")}),
+		sandboxSecret("existing-default", "team-a-sandbox", map[string][]byte{"KEY": []byte("sandbox")}),
+		sandboxSecret("existing-default", "team-a", map[string][]byte{"KEY": []byte("prod")}),
+	)
+	client := tbac.NewClient(clientSet)
+	client.Now = func() time.Time { return time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	assert.Nil(t, PromoteSecret(context.Background(), client, "team-a-sandbox", "team-a", "new-default", false))
+	promoted, err := clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "new-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "false", promoted.Labels["tbac.bisnode.com/sandbox"])
+	assert.Equal(t, "2020-05-01 12:00:00 +0000 UTC", promoted.Annotations["tbac.bisnode.com/last-modified"])
+	assert.Equal(t, []byte("sandbox"), promoted.Data["KEY"])
+
+	err = PromoteSecret(context.Background(), client, "team-a-sandbox", "team-a", "existing-default", false)
+	assert.Equal(t, 8, tbacerrors.ExitCode(err))
+	assert.Nil(t, PromoteSecret(context.Background(), client, "team-a-sandbox", "team-a", "existing-default", true))
+	replaced, err := clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "existing-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("sandbox"), replaced.Data["KEY"])
+
+	// The sandbox copy is left untouched.
+	original, err := clientSet.CoreV1().Secrets("team-a-sandbox").Get(context.Background(), "new-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "true", original.Labels["tbac.bisnode.com/sandbox"])
+}
+
+func TestSandboxPreflight(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(sandboxSecret("one-default", "team-a-sandbox", nil))
+	denyVerbs(clientSet, "delete", "update")
+	rt := NewRuntime(clientSet, "team-a")
+	rt.Preflight = true
+	rt.Printer = &Printer{Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}}
+
+	// Denied before asking for confirmation.
+	err := SandboxReset(context.Background(), rt, &SandboxOptions{})
+	assert.True(t, tbacerrors.Is(err, tbacerrors.Forbidden))
+	assert.Contains(t, err.Error(), "delete secrets in namespace team-a-sandbox")
+
+	err = SandboxPromote(context.Background(), rt, &SandboxOptions{Overwrite: true}, []string{"one-default"})
+	assert.True(t, tbacerrors.Is(err, tbacerrors.Forbidden))
+	assert.Contains(t, err.Error(), "update secrets in namespace team-a")
+	_, err = clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "one-default", metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestSandboxResetConfirmation(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(sandboxSecret("one", "team-a-sandbox", nil))
+	rt := NewRuntime(clientSet, "team-a")
+	rt.In = strings.NewReader("n\n")
+	rt.Printer = &Printer{Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}}
+
+	assert.Nil(t, SandboxReset(context.Background(), rt, &SandboxOptions{}))
+	_, err := clientSet.CoreV1().Secrets("team-a-sandbox").Get(context.Background(), "one", metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	rt.In = strings.NewReader("y\n")
+	assert.Nil(t, SandboxReset(context.Background(), rt, &SandboxOptions{}))
+	_, err = clientSet.CoreV1().Secrets("team-a-sandbox").Get(context.Background(), "one", metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestSandboxResetCanceled(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(sandboxSecret("one", "team-a-sandbox", nil))
+	rt := NewRuntime(clientSet, "team-a")
+	// Nobody answers the question before the command is canceled.
+	answer, _ := io.Pipe()
+	rt.In = answer
+	rt.Printer = &Printer{Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := SandboxReset(ctx, rt, &SandboxOptions{})
+	assert.Equal(t, tbacerrors.Canceled, tbacerrors.KindOf(err))
+	_, err = clientSet.CoreV1().Secrets("team-a-sandbox").Get(context.Background(), "one", metav1.GetOptions{})
+	assert.Nil(t, err)
+}
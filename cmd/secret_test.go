@@ -1,13 +1,23 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/Bisnode/kubectl-tbac/pkg/tbac"
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 // GenerateSecrets is a set of secret definitions.
@@ -52,17 +62,18 @@ var GenerateSecrets = []v1.Secret{
 func TestGetSecretsList(t *testing.T) {
 	// create the 'fake' clientSet where clientSet.Interface = &Clientset{}, setting all the 'fake' methods
 	// as seen in https://github.com/kubernetes/client-go/blob/master/kubernetes/fake/clientSet_generated.go
+	t.Parallel()
 	clientSet := fake.NewSimpleClientset()
-	Namespace = "default"
+	rt := NewRuntime(clientSet, "default")
 
 	for _, s := range GenerateSecrets {
-		_, err := clientSet.CoreV1().Secrets(Namespace).Create(&s)
+		_, err := clientSet.CoreV1().Secrets("default").Create(context.Background(), &s, metav1.CreateOptions{})
 		if err != nil {
 			assert.Equal(t, nil, err)
 		}
 	}
 
-	secretList, err := GetSecretList(clientSet)
+	secretList, err := GetSecretList(context.Background(), rt)
 	if err != nil {
 		assert.Equal(t, nil, err)
 	}
@@ -71,18 +82,68 @@ func TestGetSecretsList(t *testing.T) {
 	assert.Contains(t, secretList, "my-api-key")
 }
 
+func TestGetSecretListAllNamespaces(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(
+		sandboxSecret("b-secret", "team-a", nil),
+		sandboxSecret("a-secret", "team-a", nil),
+		sandboxSecret("sandbox-secret", "team-a-sandbox", nil),
+		sandboxSecret("hidden", "team-b", nil),
+	)
+	clientSet.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "team-b" {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", fmt.Errorf("denied"))
+		}
+		return false, nil, nil
+	})
+
+	secrets, forbidden, err := GetSecretListAllNamespaces(context.Background(), tbac.NewClient(clientSet), []string{"team-b", "team-a-sandbox", "team-a"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"team-b"}, forbidden)
+	var names []string
+	for _, s := range secrets {
+		names = append(names, s.Namespace+"/"+s.Name)
+	}
+	assert.Equal(t, []string{"team-a/a-secret", "team-a/b-secret", "team-a-sandbox/sandbox-secret"}, names)
+
+	clientSet.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("connection refused")
+	})
+	_, _, err = GetSecretListAllNamespaces(context.Background(), tbac.NewClient(clientSet), []string{"team-a"})
+	assert.NotNil(t, err)
+}
+
+func TestGetSecretAllTeams(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset()
+	rt := NewRuntime(clientSet, "team-a")
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+	rt.Now = func() time.Time { return time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC) }
+	assert.Nil(t, CreateSecret(context.Background(), rt, &CreateSecretOptions{Name: "debug", Data: []string{"TOKEN=foo"}, TTL: "2h"}))
+
+	out.Reset()
+	assert.Nil(t, GetSecret(context.Background(), rt, &GetSecretOptions{AllTeams: true}))
+	assert.Equal(t, "NAMESPACE   NAME\n"+
+		"team-a      debug-default (expires in 120m)\n", out.String())
+
+	assert.Error(t, GetSecret(context.Background(), rt, &GetSecretOptions{AllTeams: true, Orphaned: true}))
+	assert.Error(t, GetSecret(context.Background(), rt, &GetSecretOptions{AllTeams: true, RotationDue: true}))
+}
+
 func TestDescribeOneSecret(t *testing.T) {
+	t.Parallel()
 	clientSet := fake.NewSimpleClientset()
-	Namespace = "default"
+	rt := NewRuntime(clientSet, "default")
 
 	for _, s := range GenerateSecrets {
-		_, err := clientSet.CoreV1().Secrets(Namespace).Create(&s)
+		_, err := clientSet.CoreV1().Secrets("default").Create(context.Background(), &s, metav1.CreateOptions{})
 		if err != nil {
 			assert.Equal(t, nil, err)
 		}
 	}
 
-	secretDescription, err := GetSecretDescription(clientSet, "my-credentials")
+	secretDescription, err := GetSecretDescription(context.Background(), rt, "my-credentials")
 	if err != nil {
 		assert.Equal(t, nil, err)
 	}
@@ -91,22 +152,32 @@ func TestDescribeOneSecret(t *testing.T) {
 	assert.Equal(t, []byte("bar"), secretDescription.Data["PASSWORD"])
 }
 
+func TestDescribeMissingSecret(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset()
+	rt := NewRuntime(clientSet, "default")
+
+	_, err := GetSecretDescription(context.Background(), rt, "does-not-exist")
+	assert.True(t, tbacerrors.Is(err, tbacerrors.NotFound))
+}
+
 func TestDeleteSecret(t *testing.T) {
+	t.Parallel()
 	clientSet := fake.NewSimpleClientset()
-	Namespace = "default"
+	rt := NewRuntime(clientSet, "default")
 
 	for _, s := range GenerateSecrets {
-		_, err := clientSet.CoreV1().Secrets(Namespace).Create(&s)
+		_, err := clientSet.CoreV1().Secrets("default").Create(context.Background(), &s, metav1.CreateOptions{})
 		if err != nil {
 			assert.Equal(t, nil, err)
 		}
 	}
 
-	err := DeleteSecret(clientSet, "my-credentials")
+	err := DeleteSecret(context.Background(), rt, &DeleteSecretOptions{Name: "my-credentials"})
 	if err != nil {
 		assert.Equal(t, nil, err)
 	}
-	secretList, err := clientSet.CoreV1().Secrets(Namespace).List(metav1.ListOptions{})
+	secretList, err := clientSet.CoreV1().Secrets("default").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
 		assert.Equal(t, nil, err)
 	}
@@ -119,29 +190,30 @@ func TestDeleteSecret(t *testing.T) {
 }
 
 func TestPatchSecret(t *testing.T) {
+	t.Parallel()
 	clientSet := fake.NewSimpleClientset()
-	Namespace = "default"
+	rt := NewRuntime(clientSet, "default")
 
 	for _, s := range GenerateSecrets {
-		_, err := clientSet.CoreV1().Secrets(Namespace).Create(&s)
+		_, err := clientSet.CoreV1().Secrets("default").Create(context.Background(), &s, metav1.CreateOptions{})
 		if err != nil {
 			assert.Equal(t, nil, err)
 		}
 	}
 
-	secretName := "my-credentials"
-	removeData := []string{"USERNAME"}
-	updateData := []string{
-		"PASSWORD=snowmobile2020",
-		"URL=my-api.com",
-	}
-
-	err := PatchSecret(clientSet, &secretName, &removeData, &updateData)
+	err := PatchSecret(context.Background(), rt, &PatchSecretOptions{
+		Name:       "my-credentials",
+		RemoveData: []string{"USERNAME"},
+		Data: []string{
+			"PASSWORD=snowmobile2020",
+			"URL=my-api.com",
+		},
+	})
 	if err != nil {
 		assert.Equal(t, nil, err)
 	}
 
-	updatedSecret, err := clientSet.CoreV1().Secrets(Namespace).Get("my-credentials", metav1.GetOptions{})
+	updatedSecret, err := clientSet.CoreV1().Secrets("default").Get(context.Background(), "my-credentials", metav1.GetOptions{})
 	if err != nil {
 		assert.Equal(t, nil, err)
 	}
@@ -156,23 +228,136 @@ func TestPatchSecret(t *testing.T) {
 }
 
 func TestCreateSecret(t *testing.T) {
+	t.Parallel()
 	clientSet := fake.NewSimpleClientset()
-	Namespace = "default"
+	rt := NewRuntime(clientSet, "default")
 
-	secretName := "new-app-secret"
-	container := "default"
-	data := []string{
-		"USERNAME=foo",
-		"PASSWORD=bar",
-	}
-	err := CreateSecret(clientSet, &secretName, &container, data)
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+	err := CreateSecret(context.Background(), rt, &CreateSecretOptions{
+		Name:      "new-app-secret",
+		Container: "default",
+		Data: []string{
+			"USERNAME=foo",
+			"PASSWORD=bar",
+		},
+	})
 	if err != nil {
 		assert.Equal(t, nil, err)
 	}
-	createdSecret, err := clientSet.CoreV1().Secrets(Namespace).Get("new-app-secret-default", metav1.GetOptions{})
+	createdSecret, err := clientSet.CoreV1().Secrets("default").Get(context.Background(), "new-app-secret-default", metav1.GetOptions{})
 	if err != nil {
 		assert.Equal(t, nil, err)
 	}
 	assert.Equal(t, []byte("foo"), createdSecret.Data["USERNAME"])
 	assert.Equal(t, []byte("bar"), createdSecret.Data["PASSWORD"])
+	assert.Equal(t, "false", createdSecret.Labels["tbac.bisnode.com/sandbox"])
+	assert.Equal(t, "Created secret/new-app-secret-default in namespace default\n", out.String())
+}
+
+func TestCreateSecretExists(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset()
+	rt := NewRuntime(clientSet, "default")
+	errOut := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: &bytes.Buffer{}, ErrOut: errOut}
+	o := &CreateSecretOptions{Name: "my-app", Data: []string{"KEY=value"}}
+
+	assert.Nil(t, CreateSecret(context.Background(), rt, o))
+	err := CreateSecret(context.Background(), rt, o)
+	assert.Equal(t, 8, tbacerrors.ExitCode(err))
+	// The error is only printed once, by the caller.
+	assert.Empty(t, errOut.String())
+}
+
+func TestDescribeSecretUsedBy(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(
+		sandboxSecret("my-app-default", "team-a", nil),
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "team-a"},
+			Spec: v1.PodSpec{Volumes: []v1.Volume{
+				{Name: "secret", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "my-app-default"}}},
+			}},
+		},
+	)
+	rt := NewRuntime(clientSet, "team-a")
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: errOut}
+
+	assert.Nil(t, GetSecret(context.Background(), rt, &GetSecretOptions{Name: "my-app-default"}))
+	assert.Contains(t, out.String(), "Used by:                 Pod/my-app (volume)\n")
+
+	// Describing still works when workloads cannot be listed.
+	clientSet.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", fmt.Errorf("denied"))
+	})
+	out.Reset()
+	assert.Nil(t, GetSecret(context.Background(), rt, &GetSecretOptions{Name: "my-app-default"}))
+	assert.NotContains(t, out.String(), "Used by:")
+	assert.Contains(t, errOut.String(), "could not find what uses the secret")
+}
+
+func TestPatchSecretRestart(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(
+		sandboxSecret("my-app", "team-a", nil),
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "team-a", Labels: map[string]string{"app": "my-app"}},
+			Status:     appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+		},
+	)
+	rt := NewRuntime(clientSet, "team-a")
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+
+	err := PatchSecret(context.Background(), rt, &PatchSecretOptions{
+		Name:           "my-app",
+		Data:           []string{"KEY=value"},
+		RestartOptions: RestartOptions{Restart: true, Wait: true, WaitTimeout: time.Second},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "secret/my-app modified\nRestarted deployment/my-app\ndeployment/my-app rolled out\n", out.String())
+	deployment, err := clientSet.AppsV1().Deployments("team-a").Get(context.Background(), "my-app", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Contains(t, deployment.Spec.Template.Annotations, "kubectl.kubernetes.io/restartedAt")
+}
+
+func TestPatchSecretRestartOnDelete(t *testing.T) {
+	t.Parallel()
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "team-a", Labels: map[string]string{"app": "my-app"}},
+	}
+	statefulSet.Spec.UpdateStrategy.Type = appsv1.OnDeleteStatefulSetStrategyType
+	clientSet := fake.NewSimpleClientset(sandboxSecret("my-app", "team-a", nil), statefulSet)
+	rt := NewRuntime(clientSet, "team-a")
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: errOut}
+
+	// The StatefulSet is not waited for, as it never rolls out by itself.
+	err := PatchSecret(context.Background(), rt, &PatchSecretOptions{
+		Name:           "my-app",
+		Data:           []string{"KEY=value"},
+		RestartOptions: RestartOptions{Restart: true, Wait: true, WaitTimeout: time.Second},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "secret/my-app modified\n", out.String())
+	assert.Equal(t, "Warning: statefulset/my-app uses the OnDelete update strategy, delete its pods to pick up the change\n", errOut.String())
+}
+
+func TestPatchSecretUnchanged(t *testing.T) {
+	t.Parallel()
+	clientSet := fake.NewSimpleClientset(sandboxSecret("my-app", "team-a", map[string][]byte{"KEY": []byte("value")}))
+	rt := NewRuntime(clientSet, "team-a")
+	out := &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: &bytes.Buffer{}}
+
+	err := PatchSecret(context.Background(), rt, &PatchSecretOptions{
+		Name:           "my-app",
+		Data:           []string{"KEY=value"},
+		RestartOptions: RestartOptions{Restart: true},
+	})
+	assert.Nil(t, err)
+	// Nothing changed, so nothing is restarted.
+	assert.Equal(t, "secret/my-app unchanged\n", out.String())
 }
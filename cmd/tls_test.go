@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// writeCertificate writes a self-signed certificate for dnsName and its key to
+// dir, and returns their paths.
+func writeCertificate(t *testing.T, dir, dnsName string, notAfter time.Time) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile = filepath.Join(dir, dnsName+".crt")
+	keyFile = filepath.Join(dir, dnsName+".key")
+	assert.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestCreateTLSSecret(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "kubectl-tbac")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	certFile, keyFile := writeCertificate(t, dir, "my-app.example.com", now.Add(10*24*time.Hour))
+	_, otherKeyFile := writeCertificate(t, dir, "other.example.com", now.Add(10*24*time.Hour))
+
+	clientSet := fake.NewSimpleClientset()
+	rt := NewRuntime(clientSet, "team-a")
+	rt.Now = func() time.Time { return now }
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	rt.Printer = &Printer{Out: out, ErrOut: errOut}
+
+	err = CreateTLSSecret(context.Background(), rt, &CreateTLSSecretOptions{Name: "other", CertFile: certFile, KeyFile: otherKeyFile})
+	assert.Contains(t, err.Error(), "invalid certificate or key")
+
+	assert.Nil(t, CreateTLSSecret(context.Background(), rt, &CreateTLSSecretOptions{Name: "my-app", Container: "default", CertFile: certFile, KeyFile: keyFile}))
+	assert.Contains(t, errOut.String(), "expires in 10 days")
+
+	out.Reset()
+	assert.Nil(t, GetSecret(context.Background(), rt, &GetSecretOptions{}))
+	assert.Equal(t, " * my-app-default (tls, CN=my-app.example.com, SANs: my-app.example.com, expires in 10 days)\n", out.String())
+
+	out.Reset()
+	assert.Nil(t, GetSecret(context.Background(), rt, &GetSecretOptions{Name: "my-app-default"}))
+	assert.Contains(t, out.String(), "Subject:                 CN=my-app.example.com\n")
+	assert.Contains(t, out.String(), "Expires:                 2020-05-11T12:00:00Z\n")
+
+	assert.NotNil(t, GetSecret(context.Background(), rt, &GetSecretOptions{Name: "my-app-default", Export: true}))
+}
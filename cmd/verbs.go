@@ -16,8 +16,6 @@ limitations under the License.
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
 )
 
@@ -26,9 +24,6 @@ var patchCmd = &cobra.Command{
 	Use:              "patch",
 	TraverseChildren: true,
 	Short:            "Patch a resource in team namespace",
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		identifyTeam()
-	},
 }
 
 // getCmd represents the get command
@@ -36,9 +31,6 @@ var getCmd = &cobra.Command{
 	Use:              "get",
 	TraverseChildren: true,
 	Short:            "Get resources in team namespace",
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		identifyTeam()
-	},
 }
 
 // deleteCmd represents the delete command
@@ -46,9 +38,6 @@ var deleteCmd = &cobra.Command{
 	Use:              "delete",
 	TraverseChildren: true,
 	Short:            "Delete a resource in team namespace",
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		identifyTeam()
-	},
 }
 
 // createCmd represents the create command
@@ -56,17 +45,36 @@ var createCmd = &cobra.Command{
 	Use:              "create",
 	TraverseChildren: true,
 	Short:            "Create a resource in team namespace",
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		identifyTeam()
-	},
+}
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:              "compare",
+	TraverseChildren: true,
+	Short:            "Compare resources in team namespace and its sandbox",
+}
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:              "check",
+	TraverseChildren: true,
+	Short:            "Check resources in team namespace for problems",
+}
+
+// rotateCmd represents the rotate command
+var rotateCmd = &cobra.Command{
+	Use:              "rotate",
+	TraverseChildren: true,
+	Short:            "Rotate credentials in team namespace",
 }
 
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show version of kubectl-tbac",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("v%v\n", version)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cli.Printer.Dataf("v%v\n", version)
+		return nil
 	},
 }
 
@@ -75,5 +83,8 @@ func init() {
 	rootCmd.AddCommand(deleteCmd)
 	rootCmd.AddCommand(patchCmd)
 	rootCmd.AddCommand(getCmd)
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(rotateCmd)
 	rootCmd.AddCommand(versionCmd)
 }
@@ -0,0 +1,137 @@
+// Package errors defines the typed errors used by kubectl-tbac.
+//
+// Every error that reaches the command line is mapped to an exit code based on
+// its Kind, so that scripts can tell a missing context from a missing secret.
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Kind classifies an error.
+type Kind int
+
+const (
+	// Unknown is any error that has not been classified.
+	Unknown Kind = iota
+	// NoContext means that no kubeconfig context is set.
+	NoContext
+	// NoTeams means that the user is not member of any team.
+	NoTeams
+	// AmbiguousTeam means that the user is member of several teams and
+	// no namespace was given.
+	AmbiguousTeam
+	// NotFound means that the requested resource does not exist.
+	NotFound
+	// Forbidden means that the user is not allowed to perform the request.
+	Forbidden
+	// Timeout means that the Kubernetes API did not answer in time.
+	Timeout
+	// Canceled means that the command was interrupted, for example by Ctrl-C.
+	Canceled
+	// Conflict means that the resource already exists, or was changed by
+	// someone else during the request.
+	Conflict
+	// Expiring means that a certificate expires sooner than allowed.
+	Expiring
+)
+
+// exitCodes maps every Kind to the exit code of the process.
+var exitCodes = map[Kind]int{
+	Unknown:       1,
+	NoContext:     2,
+	NoTeams:       3,
+	AmbiguousTeam: 4,
+	NotFound:      5,
+	Forbidden:     6,
+	Timeout:       7,
+	Canceled:      130,
+	Conflict:      8,
+	Expiring:      9,
+}
+
+// Error is an error of a known Kind.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v: %v", e.Message, e.Err)
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error of the given kind with a formatted message.
+func New(kind Kind, format string, a ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+// Wrap returns err annotated with a kind and a message.
+func Wrap(kind Kind, err error, message string) error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// KindOf returns the kind of err. Errors returned by the Kubernetes API are
+// classified by their status reason, and interrupted or timed out requests by
+// the error of their context or connection.
+func KindOf(err error) Kind {
+	if err == nil {
+		return Unknown
+	}
+	var e *Error
+	if errors.As(err, &e) && e.Kind != Unknown {
+		return e.Kind
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		switch status.Status().Reason {
+		case metav1.StatusReasonNotFound:
+			return NotFound
+		case metav1.StatusReasonForbidden:
+			return Forbidden
+		case metav1.StatusReasonTimeout, metav1.StatusReasonServerTimeout:
+			return Timeout
+		case metav1.StatusReasonAlreadyExists, metav1.StatusReasonConflict:
+			return Conflict
+		}
+	}
+	if errors.Is(err, context.Canceled) {
+		return Canceled
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return Timeout
+	}
+	return Unknown
+}
+
+// Is reports whether err is of the given kind.
+func Is(err error, kind Kind) bool {
+	return err != nil && KindOf(err) == kind
+}
+
+// ExitCode returns the exit code to use for err. A nil error gives 0.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return exitCodes[KindOf(err)]
+}
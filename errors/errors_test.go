@@ -0,0 +1,52 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestExitCode(t *testing.T) {
+	secrets := schema.GroupResource{Resource: "secrets"}
+	tests := []struct {
+		name string
+		err  error
+		kind Kind
+		code int
+	}{
+		{"nil", nil, Unknown, 0},
+		{"unknown", fmt.Errorf("boom"), Unknown, 1},
+		{"no context", New(NoContext, "no context"), NoContext, 2},
+		{"no teams", New(NoTeams, "no teams"), NoTeams, 3},
+		{"ambiguous team", New(AmbiguousTeam, "pick one"), AmbiguousTeam, 4},
+		{"not found", New(NotFound, "not found"), NotFound, 5},
+		{"forbidden", New(Forbidden, "forbidden"), Forbidden, 6},
+		{"api not found", apierrors.NewNotFound(secrets, "my-secret"), NotFound, 5},
+		{"api forbidden", apierrors.NewForbidden(secrets, "my-secret", fmt.Errorf("denied")), Forbidden, 6},
+		{"wrapped api error", fmt.Errorf("failed: %w", apierrors.NewNotFound(secrets, "my-secret")), NotFound, 5},
+		{"wrapped typed error", Wrap(NoContext, fmt.Errorf("cause"), "no context"), NoContext, 2},
+		{"api timeout", apierrors.NewTimeoutError("slow", 1), Timeout, 7},
+		{"deadline exceeded", fmt.Errorf("get: %w", context.DeadlineExceeded), Timeout, 7},
+		{"canceled", fmt.Errorf("get: %w", context.Canceled), Canceled, 130},
+		{"already exists", apierrors.NewAlreadyExists(secrets, "my-secret"), Conflict, 8},
+		{"conflict", apierrors.NewConflict(secrets, "my-secret", fmt.Errorf("changed")), Conflict, 8},
+		{"expiring", New(Expiring, "expiring"), Expiring, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.kind, KindOf(tt.err))
+			assert.Equal(t, tt.code, ExitCode(tt.err))
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	assert.Equal(t, "no teams", New(NoTeams, "no teams").Error())
+	assert.Equal(t, "loading: cause", Wrap(Unknown, fmt.Errorf("cause"), "loading").Error())
+	assert.Equal(t, "cause", Wrap(Unknown, fmt.Errorf("cause"), "").Error())
+	assert.True(t, Is(fmt.Errorf("outer: %w", New(Forbidden, "inner")), Forbidden))
+}
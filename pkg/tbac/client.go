@@ -0,0 +1,93 @@
+// Package tbac manages Kubernetes resources in team namespaces the same way
+// kubectl-tbac does, with the labels and annotations that tbac relies on.
+//
+// Nothing in this package prints. All results are returned to the caller.
+package tbac
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Labels and annotations set on every tbac resource.
+const (
+	LabelApp               = "app"
+	LabelContainer         = "tbac.bisnode.com/container"
+	LabelSandbox           = "tbac.bisnode.com/sandbox"
+	AnnotationLastModified = "tbac.bisnode.com/last-modified"
+	AnnotationTimeCreated  = "tbac.bisnode.com/time-created"
+	AnnotationChecksum     = "tbac.bisnode.com/checksum"
+)
+
+// Client manages tbac resources through a Kubernetes clientSet.
+type Client struct {
+	clientSet kubernetes.Interface
+
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+	// Retry tells how requests failing with transient errors are retried.
+	// It defaults to DefaultRetryPolicy.
+	Retry RetryPolicy
+}
+
+// NewClient returns a Client using clientSet.
+func NewClient(clientSet kubernetes.Interface) *Client {
+	return &Client{clientSet: clientSet, Now: time.Now, Retry: DefaultRetryPolicy}
+}
+
+// Timestamp formats t the way tbac annotations store time.
+func Timestamp(t time.Time) string {
+	return fmt.Sprintf("%v", metav1.NewTime(t).Rfc3339Copy())
+}
+
+// Checksum returns the SHA-256 checksum of secret data, in hex. Keys are
+// sorted, so the checksum only changes when keys or values do.
+func Checksum(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		// Lengths keep keys and values from running into each other.
+		fmt.Fprintf(h, "%d:%s%d:", len(k), k, len(data[k]))
+		h.Write(data[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ParseTimestamp parses time the way tbac annotations store it.
+func ParseTimestamp(s string) (time.Time, error) {
+	return time.Parse("2006-01-02 15:04:05 -0700 MST", s)
+}
+
+// ParseDuration parses a duration such as "90d" or "12h". On top of the units
+// of time.ParseDuration, a number of days can be given with the suffix "d".
+func ParseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	return d, nil
+}
+
+// timestamp returns the current time formatted as in tbac annotations.
+func (c *Client) timestamp() string {
+	return Timestamp(c.Now())
+}
@@ -0,0 +1,305 @@
+package tbac
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Ways a workload can reference a secret.
+const (
+	ViaEnv              = "env"
+	ViaEnvFrom          = "envFrom"
+	ViaVolume           = "volume"
+	ViaImagePullSecrets = "imagePullSecrets"
+)
+
+// Consumer is a workload that references a secret.
+type Consumer struct {
+	// Kind is Deployment, StatefulSet, CronJob, Job, ReplicaSet or Pod.
+	Kind string
+	Name string
+	// Via tells how the secret is referenced, such as ViaEnv or ViaVolume.
+	Via []string
+}
+
+// workload is something running pods from a pod template.
+type workload struct {
+	Kind string
+	Name string
+	// Labels are the labels of the pods.
+	Labels map[string]string
+	Spec   *v1.PodSpec
+}
+
+// SecretConsumers returns the Deployments, StatefulSets, CronJobs, Jobs,
+// ReplicaSets and Pods in a namespace that reference a secret through env,
+// envFrom, volumes or imagePullSecrets. Jobs, ReplicaSets and Pods created by
+// one of the other kinds are left out.
+func (c *Client) SecretConsumers(ctx context.Context, namespace, name string) ([]Consumer, error) {
+	workloads, err := c.workloads(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var consumers []Consumer
+	for _, w := range workloads {
+		if via := secretReferences(w.Spec, name); len(via) > 0 {
+			consumers = append(consumers, Consumer{Kind: w.Kind, Name: w.Name, Via: via})
+		}
+	}
+	return consumers, nil
+}
+
+// OrphanedSecrets returns the tbac secrets in a namespace that nothing uses:
+// no pod template references them, no pod template has their app label, no
+// Ingress uses them for TLS and no ServiceAccount lists them. Secrets modified
+// less than minAge ago are left out, as are secrets without a modification
+// time. TLS and docker registry secrets are also left out when Ingresses or
+// ServiceAccounts may not be listed, since they cannot be proven unused.
+func (c *Client) OrphanedSecrets(ctx context.Context, namespace string, minAge time.Duration) ([]Secret, error) {
+	secrets, err := c.ListSecrets(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	workloads, err := c.workloads(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	referenced, complete, err := c.referencedSecrets(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	apps := map[string]bool{}
+	for _, w := range workloads {
+		if app := w.Labels[LabelApp]; app != "" {
+			apps[app] = true
+		}
+	}
+
+	var orphaned []Secret
+	for _, s := range secrets {
+		// Only secrets created by tbac have a container label.
+		if s.Container == "" || apps[s.App] || referenced[s.Name] {
+			continue
+		}
+		if s.Type != v1.SecretTypeOpaque && !complete {
+			continue
+		}
+		modified, err := ParseTimestamp(s.LastModified)
+		if err != nil || c.Now().Sub(modified) < minAge {
+			continue
+		}
+		used := false
+		for _, w := range workloads {
+			if len(secretReferences(w.Spec, s.Name)) > 0 {
+				used = true
+				break
+			}
+		}
+		if !used {
+			orphaned = append(orphaned, s)
+		}
+	}
+	return orphaned, nil
+}
+
+// referencedSecrets returns the names of the secrets in a namespace used for
+// TLS by Ingresses or listed by ServiceAccounts, as imagePullSecrets or
+// secrets. It reports false if listing either of them was forbidden.
+func (c *Client) referencedSecrets(ctx context.Context, namespace string) (map[string]bool, bool, error) {
+	referenced := map[string]bool{}
+	complete := true
+
+	var ingresses *networkingv1.IngressList
+	err := c.Retry.do(ctx, "list ingresses in "+namespace, isTransient, func() (err error) {
+		ingresses, err = c.clientSet.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	switch {
+	case tbacerrors.Is(err, tbacerrors.Forbidden):
+		complete = false
+	case err != nil:
+		return nil, false, err
+	default:
+		for _, ingress := range ingresses.Items {
+			for _, tls := range ingress.Spec.TLS {
+				referenced[tls.SecretName] = true
+			}
+		}
+	}
+
+	var serviceAccounts *v1.ServiceAccountList
+	err = c.Retry.do(ctx, "list serviceaccounts in "+namespace, isTransient, func() (err error) {
+		serviceAccounts, err = c.clientSet.CoreV1().ServiceAccounts(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	switch {
+	case tbacerrors.Is(err, tbacerrors.Forbidden):
+		complete = false
+	case err != nil:
+		return nil, false, err
+	default:
+		for _, sa := range serviceAccounts.Items {
+			for _, ref := range sa.ImagePullSecrets {
+				referenced[ref.Name] = true
+			}
+			for _, ref := range sa.Secrets {
+				referenced[ref.Name] = true
+			}
+		}
+	}
+	return referenced, complete, nil
+}
+
+// workloads returns the Deployments, StatefulSets, CronJobs, Jobs,
+// ReplicaSets and Pods in a namespace. Jobs created by a CronJob, ReplicaSets
+// created by a Deployment and Pods created by any of these are left out, since
+// they run the pod template of their controller.
+func (c *Client) workloads(ctx context.Context, namespace string) ([]workload, error) {
+	var workloads []workload
+	// covered holds the Kind/Name of the controllers whose pods are already
+	// accounted for, listed or not.
+	covered := map[string]bool{}
+	add := func(kind, name string, template *v1.PodTemplateSpec) {
+		workloads = append(workloads, workload{Kind: kind, Name: name, Labels: template.Labels, Spec: &template.Spec})
+		covered[kind+"/"+name] = true
+	}
+	// controlled reports whether obj was created by a covered controller.
+	controlled := func(obj metav1.Object) bool {
+		owner := metav1.GetControllerOf(obj)
+		return owner != nil && covered[owner.Kind+"/"+owner.Name]
+	}
+
+	var deployments *appsv1.DeploymentList
+	err := c.Retry.do(ctx, "list deployments in "+namespace, isTransient, func() (err error) {
+		deployments, err = c.clientSet.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		add("Deployment", deployments.Items[i].Name, &deployments.Items[i].Spec.Template)
+	}
+
+	var statefulSets *appsv1.StatefulSetList
+	err = c.Retry.do(ctx, "list statefulsets in "+namespace, isTransient, func() (err error) {
+		statefulSets, err = c.clientSet.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range statefulSets.Items {
+		add("StatefulSet", statefulSets.Items[i].Name, &statefulSets.Items[i].Spec.Template)
+	}
+
+	var cronJobs *batchv1.CronJobList
+	err = c.Retry.do(ctx, "list cronjobs in "+namespace, isTransient, func() (err error) {
+		cronJobs, err = c.clientSet.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range cronJobs.Items {
+		add("CronJob", cronJobs.Items[i].Name, &cronJobs.Items[i].Spec.JobTemplate.Spec.Template)
+	}
+
+	var jobs *batchv1.JobList
+	err = c.Retry.do(ctx, "list jobs in "+namespace, isTransient, func() (err error) {
+		jobs, err = c.clientSet.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs.Items {
+		if controlled(&jobs.Items[i]) {
+			covered["Job/"+jobs.Items[i].Name] = true
+			continue
+		}
+		add("Job", jobs.Items[i].Name, &jobs.Items[i].Spec.Template)
+	}
+
+	var replicaSets *appsv1.ReplicaSetList
+	err = c.Retry.do(ctx, "list replicasets in "+namespace, isTransient, func() (err error) {
+		replicaSets, err = c.clientSet.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range replicaSets.Items {
+		if controlled(&replicaSets.Items[i]) {
+			covered["ReplicaSet/"+replicaSets.Items[i].Name] = true
+			continue
+		}
+		add("ReplicaSet", replicaSets.Items[i].Name, &replicaSets.Items[i].Spec.Template)
+	}
+
+	var pods *v1.PodList
+	err = c.Retry.do(ctx, "list pods in "+namespace, isTransient, func() (err error) {
+		pods, err = c.clientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		if controlled(&pods.Items[i]) {
+			continue
+		}
+		workloads = append(workloads, workload{Kind: "Pod", Name: pods.Items[i].Name, Labels: pods.Items[i].Labels, Spec: &pods.Items[i].Spec})
+	}
+	return workloads, nil
+}
+
+// secretReferences returns how a pod spec references a secret, sorted.
+func secretReferences(spec *v1.PodSpec, name string) []string {
+	via := map[string]bool{}
+	containers := append(append([]v1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil && env.ValueFrom.SecretKeyRef.Name == name {
+				via[ViaEnv] = true
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil && envFrom.SecretRef.Name == name {
+				via[ViaEnvFrom] = true
+			}
+		}
+	}
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil && volume.Secret.SecretName == name {
+			via[ViaVolume] = true
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil && source.Secret.Name == name {
+					via[ViaVolume] = true
+				}
+			}
+		}
+	}
+	for _, ref := range spec.ImagePullSecrets {
+		if ref.Name == name {
+			via[ViaImagePullSecrets] = true
+		}
+	}
+
+	references := make([]string, 0, len(via))
+	for v := range via {
+		references = append(references, v)
+	}
+	sort.Strings(references)
+	return references
+}
@@ -0,0 +1,204 @@
+package tbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestSecretConsumers(t *testing.T) {
+	controller := true
+	clientSet := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "team-a"},
+			Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+				Containers: []v1.Container{{
+					Name: "api",
+					Env: []v1.EnvVar{{Name: "PASSWORD", ValueFrom: &v1.EnvVarSource{
+						SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "my-secret"}, Key: "PASSWORD"},
+					}}},
+				}},
+				Volumes: []v1.Volume{{Name: "secret", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "my-secret"}}}},
+			}}},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "team-a"},
+			Spec: appsv1.StatefulSetSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+				InitContainers: []v1.Container{{
+					Name:    "init",
+					EnvFrom: []v1.EnvFromSource{{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "my-secret"}}}},
+				}},
+			}}},
+		},
+		&batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "team-a"},
+			Spec: batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+				ImagePullSecrets: []v1.LocalObjectReference{{Name: "my-secret"}},
+			}}}}},
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "team-a"},
+			Spec:       v1.PodSpec{ImagePullSecrets: []v1.LocalObjectReference{{Name: "my-secret"}}},
+		},
+		// Pods of the deployment are found through the deployment.
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-12", Namespace: "team-a", OwnerReferences: []metav1.OwnerReference{
+				{Kind: "Deployment", Name: "api", Controller: &controller},
+			}},
+			Spec: appsv1.ReplicaSetSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+				ImagePullSecrets: []v1.LocalObjectReference{{Name: "my-secret"}},
+			}}},
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-1234", Namespace: "team-a", OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "api-12", Controller: &controller},
+			}},
+			Spec: v1.PodSpec{ImagePullSecrets: []v1.LocalObjectReference{{Name: "my-secret"}}},
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "team-a"},
+			Spec:       v1.PodSpec{ImagePullSecrets: []v1.LocalObjectReference{{Name: "other-secret"}}},
+		},
+	)
+
+	consumers, err := NewClient(clientSet).SecretConsumers(context.Background(), "team-a", "my-secret")
+	assert.Nil(t, err)
+	assert.Equal(t, []Consumer{
+		{Kind: "Deployment", Name: "api", Via: []string{ViaEnv, ViaVolume}},
+		{Kind: "StatefulSet", Name: "db", Via: []string{ViaEnvFrom}},
+		{Kind: "CronJob", Name: "report", Via: []string{ViaImagePullSecrets}},
+		{Kind: "Pod", Name: "debug", Via: []string{ViaImagePullSecrets}},
+	}, consumers)
+}
+
+func TestSecretConsumersStandaloneControllers(t *testing.T) {
+	controller := true
+	usesSecret := v1.PodSpec{ImagePullSecrets: []v1.LocalObjectReference{{Name: "my-secret"}}}
+	clientSet := fake.NewSimpleClientset(
+		// A Job run by hand is a consumer, and its pod is found through it.
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "team-a"},
+			Spec:       batchv1.JobSpec{Template: v1.PodTemplateSpec{Spec: usesSecret}},
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "migrate-abc", Namespace: "team-a", OwnerReferences: []metav1.OwnerReference{
+				{Kind: "Job", Name: "migrate", Controller: &controller},
+			}},
+			Spec: usesSecret,
+		},
+		// A Job of a CronJob is found through the CronJob.
+		&batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "team-a"},
+			Spec:       batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: v1.PodTemplateSpec{Spec: usesSecret}}}},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "report-1", Namespace: "team-a", OwnerReferences: []metav1.OwnerReference{
+				{Kind: "CronJob", Name: "report", Controller: &controller},
+			}},
+			Spec: batchv1.JobSpec{Template: v1.PodTemplateSpec{Spec: usesSecret}},
+		},
+		// A ReplicaSet without a Deployment is a consumer.
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "team-a"},
+			Spec:       appsv1.ReplicaSetSpec{Template: v1.PodTemplateSpec{Spec: usesSecret}},
+		},
+		// A pod whose controller is not listed, such as a DaemonSet, is a consumer.
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent-xyz", Namespace: "team-a", OwnerReferences: []metav1.OwnerReference{
+				{Kind: "DaemonSet", Name: "agent", Controller: &controller},
+			}},
+			Spec: usesSecret,
+		},
+	)
+
+	consumers, err := NewClient(clientSet).SecretConsumers(context.Background(), "team-a", "my-secret")
+	assert.Nil(t, err)
+	assert.Equal(t, []Consumer{
+		{Kind: "CronJob", Name: "report", Via: []string{ViaImagePullSecrets}},
+		{Kind: "Job", Name: "migrate", Via: []string{ViaImagePullSecrets}},
+		{Kind: "ReplicaSet", Name: "legacy", Via: []string{ViaImagePullSecrets}},
+		{Kind: "Pod", Name: "agent-xyz", Via: []string{ViaImagePullSecrets}},
+	}, consumers)
+}
+
+func TestOrphanedSecrets(t *testing.T) {
+	client, clientSet := newTestClient()
+	for _, name := range []string{"used-by-label", "used-by-volume", "orphaned"} {
+		_, err := client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{Name: name})
+		assert.Nil(t, err)
+	}
+	_, err := clientSet.AppsV1().Deployments("team-a").Create(context.Background(), &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "team-a"},
+		Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelApp: "used-by-label"}},
+			Spec: v1.PodSpec{Volumes: []v1.Volume{
+				{Name: "secret", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "used-by-volume-default"}}},
+			}},
+		}},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	orphaned, err := client.OrphanedSecrets(context.Background(), "team-a", 0)
+	assert.Nil(t, err)
+	assert.Len(t, orphaned, 1)
+	assert.Equal(t, "orphaned-default", orphaned[0].Name)
+
+	// The secrets were just modified.
+	orphaned, err = client.OrphanedSecrets(context.Background(), "team-a", time.Hour)
+	assert.Nil(t, err)
+	assert.Empty(t, orphaned)
+}
+
+func TestOrphanedSecretsIngressAndServiceAccount(t *testing.T) {
+	client, clientSet := newTestClient()
+	_, err := client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{Name: "web-tls", Type: v1.SecretTypeTLS})
+	assert.Nil(t, err)
+	_, err = client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{Name: "registry", Type: v1.SecretTypeDockerConfigJson})
+	assert.Nil(t, err)
+	_, err = client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{Name: "unused-tls", Type: v1.SecretTypeTLS})
+	assert.Nil(t, err)
+	_, err = clientSet.NetworkingV1().Ingresses("team-a").Create(context.Background(), &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a"},
+		Spec:       networkingv1.IngressSpec{TLS: []networkingv1.IngressTLS{{Hosts: []string{"example.com"}, SecretName: "web-tls-default"}}},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	_, err = clientSet.CoreV1().ServiceAccounts("team-a").Create(context.Background(), &v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "team-a"},
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry-default"}},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	orphaned, err := client.OrphanedSecrets(context.Background(), "team-a", 0)
+	assert.Nil(t, err)
+	assert.Len(t, orphaned, 1)
+	assert.Equal(t, "unused-tls-default", orphaned[0].Name)
+}
+
+func TestOrphanedSecretsIngressesForbidden(t *testing.T) {
+	client, clientSet := newTestClient()
+	_, err := client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{Name: "web-tls", Type: v1.SecretTypeTLS})
+	assert.Nil(t, err)
+	_, err = client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{Name: "orphaned"})
+	assert.Nil(t, err)
+	clientSet.PrependReactor("list", "ingresses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "networking.k8s.io", Resource: "ingresses"}, "", nil)
+	})
+
+	// The TLS secret may be used by an Ingress, so only the Opaque secret is orphaned.
+	orphaned, err := client.OrphanedSecrets(context.Background(), "team-a", 0)
+	assert.Nil(t, err)
+	assert.Len(t, orphaned, 1)
+	assert.Equal(t, "orphaned-default", orphaned[0].Name)
+}
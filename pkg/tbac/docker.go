@@ -0,0 +1,118 @@
+package tbac
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// DefaultServiceAccount is the service account pods run as unless they ask for another.
+const DefaultServiceAccount = "default"
+
+// CreateDockerRegistrySecretOptions describes a docker registry secret to create.
+type CreateDockerRegistrySecretOptions struct {
+	// Name of the secret, without the container suffix.
+	Name string
+	// Container the secret is for. Defaults to "default".
+	Container string
+	// App label of the secret. Defaults to Name.
+	App string
+	// Server is the registry, for example ghcr.io.
+	Server   string
+	Username string
+	Password string
+}
+
+// dockerConfig is the content of a kubernetes.io/dockerconfigjson secret.
+type dockerConfig struct {
+	Auths map[string]dockerAuth `json:"auths"`
+}
+
+// dockerAuth holds the credentials of one registry.
+type dockerAuth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+// CreateDockerRegistrySecret creates a kubernetes.io/dockerconfigjson secret
+// that pods can use to pull images from a private registry.
+func (c *Client) CreateDockerRegistrySecret(ctx context.Context, namespace string, o CreateDockerRegistrySecretOptions) (*Secret, error) {
+	if o.Server == "" || o.Username == "" || o.Password == "" {
+		return nil, fmt.Errorf("server, username and password are required")
+	}
+	config, err := json.Marshal(dockerConfig{Auths: map[string]dockerAuth{
+		o.Server: {
+			Username: o.Username,
+			Password: o.Password,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(o.Username + ":" + o.Password)),
+		},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return c.CreateSecret(ctx, namespace, CreateSecretOptions{
+		Name:      o.Name,
+		Container: o.Container,
+		App:       o.App,
+		Type:      v1.SecretTypeDockerConfigJson,
+		Data:      map[string][]byte{v1.DockerConfigJsonKey: config},
+	})
+}
+
+// Registries returns the registries a docker registry secret has credentials
+// for, or nil for other secrets.
+func (s *Secret) Registries() ([]string, error) {
+	if s.Type != v1.SecretTypeDockerConfigJson {
+		return nil, nil
+	}
+	var config dockerConfig
+	if err := json.Unmarshal(s.Data[v1.DockerConfigJsonKey], &config); err != nil {
+		return nil, fmt.Errorf("invalid docker config: %w", err)
+	}
+	registries := make([]string, 0, len(config.Auths))
+	for server := range config.Auths {
+		registries = append(registries, server)
+	}
+	sort.Strings(registries)
+	return registries, nil
+}
+
+// GetServiceAccount returns a service account.
+func (c *Client) GetServiceAccount(ctx context.Context, namespace, name string) (*v1.ServiceAccount, error) {
+	var sa *v1.ServiceAccount
+	err := c.Retry.do(ctx, "get serviceaccount "+namespace+"/"+name, isTransient, func() (err error) {
+		sa, err = c.clientSet.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	return sa, err
+}
+
+// AttachImagePullSecret adds a secret to the imagePullSecrets of a service
+// account, so that its pods pull images with it. It returns false if the
+// secret was already attached.
+func (c *Client) AttachImagePullSecret(ctx context.Context, namespace, serviceAccount, secretName string) (bool, error) {
+	attached := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		sa, err := c.clientSet.CoreV1().ServiceAccounts(namespace).Get(ctx, serviceAccount, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for _, ref := range sa.ImagePullSecrets {
+			if ref.Name == secretName {
+				return nil
+			}
+		}
+		sa.ImagePullSecrets = append(sa.ImagePullSecrets, v1.LocalObjectReference{Name: secretName})
+		_, err = c.clientSet.CoreV1().ServiceAccounts(namespace).Update(ctx, sa, metav1.UpdateOptions{})
+		attached = err == nil
+		return err
+	})
+	return attached, err
+}
@@ -0,0 +1,47 @@
+package tbac
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCreateDockerRegistrySecret(t *testing.T) {
+	client, clientSet := newTestClient()
+	_, err := clientSet.CoreV1().ServiceAccounts("team-a").Create(context.Background(), &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: DefaultServiceAccount, Namespace: "team-a"},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	created, err := client.CreateDockerRegistrySecret(context.Background(), "team-a", CreateDockerRegistrySecretOptions{
+		Name:     "registry",
+		Server:   "ghcr.io",
+		Username: "foo",
+		Password: "bar",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, v1.SecretTypeDockerConfigJson, created.Type)
+	var config map[string]map[string]map[string]string
+	assert.Nil(t, json.Unmarshal(created.Data[v1.DockerConfigJsonKey], &config))
+	assert.Equal(t, map[string]string{"username": "foo", "password": "bar", "auth": "Zm9vOmJhcg=="}, config["auths"]["ghcr.io"])
+	registries, err := created.Registries()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ghcr.io"}, registries)
+
+	attached, err := client.AttachImagePullSecret(context.Background(), "team-a", DefaultServiceAccount, created.Name)
+	assert.Nil(t, err)
+	assert.True(t, attached)
+	attached, err = client.AttachImagePullSecret(context.Background(), "team-a", DefaultServiceAccount, created.Name)
+	assert.Nil(t, err)
+	assert.False(t, attached)
+	sa, err := clientSet.CoreV1().ServiceAccounts("team-a").Get(context.Background(), DefaultServiceAccount, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []v1.LocalObjectReference{{Name: "registry-default"}}, sa.ImagePullSecrets)
+
+	_, err = client.CreateDockerRegistrySecret(context.Background(), "team-a", CreateDockerRegistrySecretOptions{Name: "empty", Server: "ghcr.io"})
+	assert.NotNil(t, err)
+}
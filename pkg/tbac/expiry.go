@@ -0,0 +1,42 @@
+package tbac
+
+import (
+	"context"
+	"time"
+)
+
+// AnnotationExpires holds when a temporary resource expires. Expired
+// resources are deleted by garbage collection.
+const AnnotationExpires = "tbac.bisnode.com/expires"
+
+// ExpiresAt returns when a secret expires. It reports false for secrets
+// without a valid expiry.
+func (s *Secret) ExpiresAt() (time.Time, bool) {
+	if s.Expires == "" {
+		return time.Time{}, false
+	}
+	expires, err := ParseTimestamp(s.Expires)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expires, true
+}
+
+// ExpiredSecrets returns the tbac secrets in a namespace that have expired.
+func (c *Client) ExpiredSecrets(ctx context.Context, namespace string) ([]Secret, error) {
+	secrets, err := c.ListSecrets(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var expired []Secret
+	for _, s := range secrets {
+		// Only secrets created by tbac have a container label.
+		if s.Container == "" {
+			continue
+		}
+		if expires, ok := s.ExpiresAt(); ok && !c.Now().Before(expires) {
+			expired = append(expired, s)
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,47 @@
+package tbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExpiredSecrets(t *testing.T) {
+	client, clientSet := newTestClient()
+	for _, o := range []CreateSecretOptions{
+		{Name: "debug", TTL: time.Hour},
+		{Name: "later", TTL: 48 * time.Hour},
+		{Name: "forever"},
+	} {
+		_, err := client.CreateSecret(context.Background(), "team-a", o)
+		assert.Nil(t, err)
+	}
+	// Secrets not created by tbac are never collected.
+	_, err := clientSet.CoreV1().Secrets("team-a").Create(context.Background(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "unmanaged",
+			Annotations: map[string]string{AnnotationExpires: "2020-05-01 12:00:00 +0000 UTC"},
+		},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	secret, err := client.GetSecret(context.Background(), "team-a", "debug-default")
+	assert.Nil(t, err)
+	expires, ok := secret.ExpiresAt()
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2020, 5, 1, 13, 0, 0, 0, time.UTC), expires.UTC())
+
+	expired, err := client.ExpiredSecrets(context.Background(), "team-a")
+	assert.Nil(t, err)
+	assert.Empty(t, expired)
+
+	client.Now = func() time.Time { return time.Date(2020, 5, 2, 12, 0, 0, 0, time.UTC) }
+	expired, err = client.ExpiredSecrets(context.Background(), "team-a")
+	assert.Nil(t, err)
+	assert.Len(t, expired, 1)
+	assert.Equal(t, "debug-default", expired[0].Name)
+}
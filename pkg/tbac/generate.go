@@ -0,0 +1,205 @@
+package tbac
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+)
+
+// Character sets of generated values.
+const (
+	CharsetAlphanumeric = "alphanumeric"
+	CharsetHex          = "hex"
+	CharsetBase64       = "base64"
+	CharsetUUID         = "uuid"
+)
+
+// AnnotationGenerated holds a JSON object with the length and charset of each
+// generated key, such as {"PASSWORD":"32:alphanumeric"}, so that rotation
+// generates the same kind of value.
+const AnnotationGenerated = "tbac.bisnode.com/generated"
+
+// DefaultGenerateLength is the length of generated values when none is given.
+const DefaultGenerateLength = 32
+
+const alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// GenerateSpec describes a random value to generate for a key of a secret.
+type GenerateSpec struct {
+	Key string
+	// Length is the number of characters for alphanumeric values and the
+	// number of random bytes for hex and base64 values, like openssl rand.
+	// It is ignored for uuids.
+	Length int
+	// Charset is one of the Charset constants.
+	Charset string
+}
+
+// ParseGenerateSpec parses KEY[:length[:charset]]. The length defaults to
+// DefaultGenerateLength and the charset to alphanumeric.
+func ParseGenerateSpec(s string) (GenerateSpec, error) {
+	parts := strings.Split(s, ":")
+	spec := GenerateSpec{Key: parts[0], Length: DefaultGenerateLength, Charset: CharsetAlphanumeric}
+	if spec.Key == "" || len(parts) > 3 {
+		return spec, fmt.Errorf("invalid value %q to generate, expected KEY[:length[:charset]]", s)
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		length, err := strconv.Atoi(parts[1])
+		if err != nil || length <= 0 {
+			return spec, fmt.Errorf("invalid length %q to generate %v, expected a positive number", parts[1], spec.Key)
+		}
+		spec.Length = length
+	}
+	if len(parts) > 2 {
+		switch parts[2] {
+		case CharsetAlphanumeric, CharsetHex, CharsetBase64, CharsetUUID:
+			spec.Charset = parts[2]
+		default:
+			return spec, fmt.Errorf("invalid charset %q to generate %v, expected alphanumeric, hex, base64 or uuid", parts[2], spec.Key)
+		}
+	}
+	return spec, nil
+}
+
+// String returns the spec as KEY:length:charset.
+func (g GenerateSpec) String() string {
+	return fmt.Sprintf("%v:%v:%v", g.Key, g.Length, g.Charset)
+}
+
+// Generate returns a cryptographically random value as described by the spec.
+func (g GenerateSpec) Generate() ([]byte, error) {
+	switch g.Charset {
+	case CharsetHex:
+		b, err := randomBytes(g.Length)
+		return []byte(hex.EncodeToString(b)), err
+	case CharsetBase64:
+		b, err := randomBytes(g.Length)
+		return []byte(base64.StdEncoding.EncodeToString(b)), err
+	case CharsetUUID:
+		b, err := randomBytes(16)
+		if err != nil {
+			return nil, err
+		}
+		// Version 4 and the RFC 4122 variant.
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return []byte(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])), nil
+	case CharsetAlphanumeric, "":
+		value := make([]byte, g.Length)
+		max := big.NewInt(int64(len(alphanumeric)))
+		for i := range value {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = alphanumeric[n.Int64()]
+		}
+		return value, nil
+	default:
+		return nil, fmt.Errorf("unknown charset %q", g.Charset)
+	}
+}
+
+// GenerateData generates a value for every spec.
+func GenerateData(specs []GenerateSpec) (map[string][]byte, error) {
+	data := make(map[string][]byte, len(specs))
+	for _, spec := range specs {
+		value, err := spec.Generate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %v: %w", spec.Key, err)
+		}
+		data[spec.Key] = value
+	}
+	return data, nil
+}
+
+// RotateSecret replaces keys of an existing secret with newly generated
+// values, and returns the patched secret and the generated values. Every key
+// must already be in the secret. A spec with neither a length nor a charset
+// regenerates the key the way it was generated before, which must be known.
+func (c *Client) RotateSecret(ctx context.Context, namespace, name string, specs []GenerateSpec) (*Secret, map[string][]byte, error) {
+	secret, err := c.GetSecret(ctx, namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolved := make([]GenerateSpec, 0, len(specs))
+	for _, spec := range specs {
+		if _, found := secret.Data[spec.Key]; !found {
+			return nil, nil, tbacerrors.New(tbacerrors.NotFound, "key %v not found in secret %v", spec.Key, name)
+		}
+		if spec.Length == 0 && spec.Charset == "" {
+			previous, found := secret.Generated[spec.Key]
+			if !found {
+				return nil, nil, fmt.Errorf("key %v of secret %v was not generated by tbac, give it as KEY:length:charset", spec.Key, name)
+			}
+			spec = previous
+		}
+		resolved = append(resolved, spec)
+	}
+	data, err := GenerateData(resolved)
+	if err != nil {
+		return nil, nil, err
+	}
+	patched, _, err := c.PatchSecret(ctx, namespace, PatchSecretOptions{Name: name, Data: data, Generated: resolved})
+	if err != nil {
+		return nil, nil, err
+	}
+	return patched, data, nil
+}
+
+// generatedAnnotation returns the AnnotationGenerated annotation of a secret
+// whose data is set to data, where the keys of specs were generated. Other
+// keys in changed were given by hand and lose their spec, and keys no longer
+// in data are dropped.
+func generatedAnnotation(annotations map[string]string, data map[string][]byte, changed map[string][]byte, specs []GenerateSpec) (string, error) {
+	generated := parseGenerated(annotations[AnnotationGenerated])
+	if generated == nil {
+		generated = map[string]GenerateSpec{}
+	}
+	for k := range changed {
+		delete(generated, k)
+	}
+	for _, spec := range specs {
+		generated[spec.Key] = spec
+	}
+	values := make(map[string]string, len(generated))
+	for k, spec := range generated {
+		if _, found := data[k]; found {
+			values[k] = fmt.Sprintf("%v:%v", spec.Length, spec.Charset)
+		}
+	}
+	b, err := json.Marshal(values)
+	return string(b), err
+}
+
+// parseGenerated parses the AnnotationGenerated annotation, giving nil if it
+// is missing. Invalid entries count as keys that were not generated.
+func parseGenerated(annotation string) map[string]GenerateSpec {
+	if annotation == "" {
+		return nil
+	}
+	generated := map[string]GenerateSpec{}
+	values := map[string]string{}
+	_ = json.Unmarshal([]byte(annotation), &values)
+	for k, v := range values {
+		if spec, err := ParseGenerateSpec(k + ":" + v); err == nil {
+			generated[k] = spec
+		}
+	}
+	return generated
+}
+
+// randomBytes returns n cryptographically random bytes.
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, err
+}
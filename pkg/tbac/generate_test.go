@@ -0,0 +1,112 @@
+package tbac
+
+import (
+	"context"
+	"encoding/base64"
+	"regexp"
+	"testing"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGenerateSpec(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    GenerateSpec
+		wantErr bool
+	}{
+		{in: "PASSWORD", want: GenerateSpec{Key: "PASSWORD", Length: 32, Charset: CharsetAlphanumeric}},
+		{in: "PASSWORD:16", want: GenerateSpec{Key: "PASSWORD", Length: 16, Charset: CharsetAlphanumeric}},
+		{in: "SALT:8:hex", want: GenerateSpec{Key: "SALT", Length: 8, Charset: CharsetHex}},
+		{in: "ID::uuid", want: GenerateSpec{Key: "ID", Length: 32, Charset: CharsetUUID}},
+		{in: "", wantErr: true},
+		{in: ":16", wantErr: true},
+		{in: "KEY:zero", wantErr: true},
+		{in: "KEY:-1", wantErr: true},
+		{in: "KEY:16:emoji", wantErr: true},
+		{in: "KEY:16:hex:extra", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGenerateSpec(tt.in)
+		if tt.wantErr {
+			assert.Error(t, err, tt.in)
+			continue
+		}
+		assert.Nil(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		spec    GenerateSpec
+		pattern string
+	}{
+		{spec: GenerateSpec{Length: 20, Charset: CharsetAlphanumeric}, pattern: `^[A-Za-z0-9]{20}$`},
+		{spec: GenerateSpec{Length: 8, Charset: CharsetHex}, pattern: `^[0-9a-f]{16}$`},
+		{spec: GenerateSpec{Length: 6, Charset: CharsetBase64}, pattern: `^[A-Za-z0-9+/]{8}$`},
+		{spec: GenerateSpec{Charset: CharsetUUID}, pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+	}
+	for _, tt := range tests {
+		value, err := tt.spec.Generate()
+		assert.Nil(t, err)
+		assert.Regexp(t, regexp.MustCompile(tt.pattern), string(value), tt.spec.Charset)
+	}
+
+	value, err := GenerateSpec{Length: 6, Charset: CharsetBase64}.Generate()
+	assert.Nil(t, err)
+	decoded, err := base64.StdEncoding.DecodeString(string(value))
+	assert.Nil(t, err)
+	assert.Len(t, decoded, 6)
+
+	a, _ := GenerateSpec{Length: 32}.Generate()
+	b, _ := GenerateSpec{Length: 32}.Generate()
+	assert.NotEqual(t, a, b)
+}
+
+func TestRotateSecret(t *testing.T) {
+	client, _ := newTestClient()
+	_, err := client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{
+		Name: "my-app",
+		Data: map[string][]byte{"USERNAME": []byte("foo"), "PASSWORD": []byte("bar")},
+	})
+	assert.Nil(t, err)
+
+	rotated, data, err := client.RotateSecret(context.Background(), "team-a", "my-app-default", []GenerateSpec{{Key: "PASSWORD", Length: 12, Charset: CharsetHex}})
+	assert.Nil(t, err)
+	assert.Len(t, data["PASSWORD"], 24)
+	assert.Equal(t, data["PASSWORD"], rotated.Data["PASSWORD"])
+	assert.Equal(t, []byte("foo"), rotated.Data["USERNAME"])
+
+	_, _, err = client.RotateSecret(context.Background(), "team-a", "my-app-default", []GenerateSpec{{Key: "TOKEN", Length: 12}})
+	assert.True(t, tbacerrors.Is(err, tbacerrors.NotFound))
+	secret, err := client.GetSecret(context.Background(), "team-a", "my-app-default")
+	assert.Nil(t, err)
+	assert.NotContains(t, secret.Data, "TOKEN")
+}
+
+func TestRotateSecretAsGenerated(t *testing.T) {
+	client, _ := newTestClient()
+	specs := []GenerateSpec{{Key: "TOKEN", Length: 32, Charset: CharsetUUID}}
+	data, err := GenerateData(specs)
+	assert.Nil(t, err)
+	data["USERNAME"] = []byte("foo")
+	_, err = client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{Name: "my-app", Data: data, Generated: specs})
+	assert.Nil(t, err)
+
+	// A bare key is generated the way it was created.
+	rotated, data, err := client.RotateSecret(context.Background(), "team-a", "my-app-default", []GenerateSpec{{Key: "TOKEN"}})
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), string(data["TOKEN"]))
+	assert.Equal(t, map[string]GenerateSpec{"TOKEN": specs[0]}, rotated.Generated)
+
+	// Keys given by hand have no known way to be generated.
+	_, _, err = client.RotateSecret(context.Background(), "team-a", "my-app-default", []GenerateSpec{{Key: "USERNAME"}})
+	assert.EqualError(t, err, "key USERNAME of secret my-app-default was not generated by tbac, give it as KEY:length:charset")
+	patched, _, err := client.PatchSecret(context.Background(), "team-a", PatchSecretOptions{Name: "my-app-default", Data: map[string][]byte{"TOKEN": []byte("chosen")}})
+	assert.Nil(t, err)
+	assert.Empty(t, patched.Generated)
+	_, _, err = client.RotateSecret(context.Background(), "team-a", "my-app-default", []GenerateSpec{{Key: "TOKEN"}})
+	assert.NotNil(t, err)
+}
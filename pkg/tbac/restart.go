@@ -0,0 +1,167 @@
+package tbac
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// AnnotationRestartedAt is the pod template annotation kubectl rollout restart sets.
+const AnnotationRestartedAt = "kubectl.kubernetes.io/restartedAt"
+
+// Rollout is a Deployment or StatefulSet restarted to pick up a changed secret.
+type Rollout struct {
+	Kind string
+	Name string
+	// OnDelete is set for StatefulSets with the OnDelete update strategy,
+	// whose pods only pick up the restart once they are deleted.
+	OnDelete bool
+}
+
+// RestartWorkloads restarts the Deployments and StatefulSets labelled with the
+// app label of a secret, the same way kubectl rollout restart does. Unless
+// container is "default", only workloads with a container of that name are
+// restarted. Secrets without an app label cannot tell which workloads use
+// them and give an error.
+func (c *Client) RestartWorkloads(ctx context.Context, namespace, app, container string) ([]Rollout, error) {
+	if app == "" {
+		return nil, fmt.Errorf("the secret has no %v label to find its workloads by", LabelApp)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{AnnotationRestartedAt: c.Now().Format(time.RFC3339)},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	selector := metav1.ListOptions{LabelSelector: LabelApp + "=" + app}
+
+	var deployments *appsv1.DeploymentList
+	err = c.Retry.do(ctx, "list deployments in "+namespace, isTransient, func() (err error) {
+		deployments, err = c.clientSet.AppsV1().Deployments(namespace).List(ctx, selector)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var statefulSets *appsv1.StatefulSetList
+	err = c.Retry.do(ctx, "list statefulsets in "+namespace, isTransient, func() (err error) {
+		statefulSets, err = c.clientSet.AppsV1().StatefulSets(namespace).List(ctx, selector)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rollouts []Rollout
+	for _, d := range deployments.Items {
+		if !hasContainer(&d.Spec.Template.Spec, container) {
+			continue
+		}
+		// Restarting twice does no harm, so the patch is retried like a read.
+		err := c.Retry.do(ctx, "restart deployment "+namespace+"/"+d.Name, isTransient, func() error {
+			_, err := c.clientSet.AppsV1().Deployments(namespace).Patch(ctx, d.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+			return err
+		})
+		if err != nil {
+			return rollouts, err
+		}
+		rollouts = append(rollouts, Rollout{Kind: "Deployment", Name: d.Name})
+	}
+	for _, s := range statefulSets.Items {
+		if !hasContainer(&s.Spec.Template.Spec, container) {
+			continue
+		}
+		err := c.Retry.do(ctx, "restart statefulset "+namespace+"/"+s.Name, isTransient, func() error {
+			_, err := c.clientSet.AppsV1().StatefulSets(namespace).Patch(ctx, s.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+			return err
+		})
+		if err != nil {
+			return rollouts, err
+		}
+		rollouts = append(rollouts, Rollout{
+			Kind:     "StatefulSet",
+			Name:     s.Name,
+			OnDelete: s.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType,
+		})
+	}
+	return rollouts, nil
+}
+
+// WaitForRollout polls a restarted workload every interval until all its
+// replicas are updated and available, or ctx is done. StatefulSets with the
+// OnDelete update strategy never roll out by themselves and give an error
+// right away.
+func (c *Client) WaitForRollout(ctx context.Context, namespace string, r Rollout, interval time.Duration) error {
+	if r.OnDelete {
+		return fmt.Errorf("%v %v uses the OnDelete update strategy and is not rolled out until its pods are deleted", r.Kind, r.Name)
+	}
+	return wait.PollUntilContextCancel(ctx, interval, true, func(ctx context.Context) (bool, error) {
+		switch r.Kind {
+		case "Deployment":
+			d, err := c.clientSet.AppsV1().Deployments(namespace).Get(ctx, r.Name, metav1.GetOptions{})
+			if err != nil {
+				return false, ignoreTransient(err)
+			}
+			replicas := replicasOf(d.Spec.Replicas)
+			return d.Status.ObservedGeneration >= d.Generation &&
+				d.Status.UpdatedReplicas == replicas &&
+				d.Status.Replicas == replicas &&
+				d.Status.AvailableReplicas == replicas, nil
+		case "StatefulSet":
+			s, err := c.clientSet.AppsV1().StatefulSets(namespace).Get(ctx, r.Name, metav1.GetOptions{})
+			if err != nil {
+				return false, ignoreTransient(err)
+			}
+			replicas := replicasOf(s.Spec.Replicas)
+			return s.Status.ObservedGeneration >= s.Generation &&
+				s.Status.UpdatedReplicas == replicas &&
+				s.Status.ReadyReplicas == replicas &&
+				s.Status.CurrentRevision == s.Status.UpdateRevision, nil
+		default:
+			return false, fmt.Errorf("cannot wait for rollout of %v", r.Kind)
+		}
+	})
+}
+
+// hasContainer reports whether a pod spec has the named container. Every pod
+// spec has the "default" container.
+func hasContainer(spec *v1.PodSpec, container string) bool {
+	if container == "" || container == "default" {
+		return true
+	}
+	for _, c := range spec.Containers {
+		if c.Name == container {
+			return true
+		}
+	}
+	return false
+}
+
+// replicasOf returns the desired number of replicas, which defaults to 1.
+func replicasOf(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// ignoreTransient returns nil for transient errors, so that polling goes on.
+func ignoreTransient(err error) error {
+	if isTransient(err) {
+		return nil
+	}
+	return err
+}
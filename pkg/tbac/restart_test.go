@@ -0,0 +1,91 @@
+package tbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// appDeployment returns a deployment of app with the given containers.
+func appDeployment(name, app string, containers ...string) *appsv1.Deployment {
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a", Labels: map[string]string{LabelApp: app}},
+	}
+	for _, c := range containers {
+		d.Spec.Template.Spec.Containers = append(d.Spec.Template.Spec.Containers, v1.Container{Name: c})
+	}
+	return d
+}
+
+func TestRestartWorkloads(t *testing.T) {
+	client, clientSet := newTestClient()
+	for _, d := range []*appsv1.Deployment{
+		appDeployment("api", "my-app", "api", "opa"),
+		appDeployment("worker", "my-app", "worker"),
+		appDeployment("other", "other-app", "other", "opa"),
+	} {
+		_, err := clientSet.AppsV1().Deployments("team-a").Create(context.Background(), d, metav1.CreateOptions{})
+		assert.Nil(t, err)
+	}
+
+	rollouts, err := client.RestartWorkloads(context.Background(), "team-a", "my-app", "default")
+	assert.Nil(t, err)
+	assert.Equal(t, []Rollout{{Kind: "Deployment", Name: "api"}, {Kind: "Deployment", Name: "worker"}}, rollouts)
+	api, err := clientSet.AppsV1().Deployments("team-a").Get(context.Background(), "api", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "2020-05-01T12:00:00Z", api.Spec.Template.Annotations[AnnotationRestartedAt])
+	other, err := clientSet.AppsV1().Deployments("team-a").Get(context.Background(), "other", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, other.Spec.Template.Annotations)
+
+	// Sidecar secrets only restart workloads with the sidecar.
+	rollouts, err = client.RestartWorkloads(context.Background(), "team-a", "my-app", "opa")
+	assert.Nil(t, err)
+	assert.Equal(t, []Rollout{{Kind: "Deployment", Name: "api"}}, rollouts)
+
+	_, err = client.RestartWorkloads(context.Background(), "team-a", "", "default")
+	assert.NotNil(t, err)
+}
+
+func TestRestartWorkloadsOnDelete(t *testing.T) {
+	client, clientSet := newTestClient()
+	s := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "team-a", Labels: map[string]string{LabelApp: "my-app"}},
+	}
+	s.Spec.UpdateStrategy.Type = appsv1.OnDeleteStatefulSetStrategyType
+	_, err := clientSet.AppsV1().StatefulSets("team-a").Create(context.Background(), s, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	rollouts, err := client.RestartWorkloads(context.Background(), "team-a", "my-app", "default")
+	assert.Nil(t, err)
+	assert.Equal(t, []Rollout{{Kind: "StatefulSet", Name: "db", OnDelete: true}}, rollouts)
+
+	// Waiting fails right away rather than until ctx is done.
+	assert.NotNil(t, client.WaitForRollout(context.Background(), "team-a", rollouts[0], time.Millisecond))
+}
+
+func TestWaitForRollout(t *testing.T) {
+	client, clientSet := newTestClient()
+	replicas := int32(2)
+	done := appDeployment("done", "my-app", "api")
+	done.Spec.Replicas = &replicas
+	done.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}
+	rolling := appDeployment("rolling", "my-app", "api")
+	rolling.Spec.Replicas = &replicas
+	rolling.Status = appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 2}
+	for _, d := range []*appsv1.Deployment{done, rolling} {
+		_, err := clientSet.AppsV1().Deployments("team-a").Create(context.Background(), d, metav1.CreateOptions{})
+		assert.Nil(t, err)
+	}
+
+	assert.Nil(t, client.WaitForRollout(context.Background(), "team-a", Rollout{Kind: "Deployment", Name: "done"}, time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.NotNil(t, client.WaitForRollout(ctx, "team-a", Rollout{Kind: "Deployment", Name: "rolling"}, time.Millisecond))
+}
@@ -0,0 +1,92 @@
+package tbac
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// RetryPolicy tells how requests that fail with transient errors, such as
+// throttling, timeouts and server errors, are retried.
+type RetryPolicy struct {
+	// Retries is how many times a failed request is retried. Zero disables retries.
+	Retries int
+	// Backoff is the wait before the first retry. It doubles with every
+	// retry, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter adds a random wait of up to this fraction of the backoff, so
+	// that clients failing at the same time do not retry at the same time.
+	Jitter float64
+	// OnRetry is called before waiting for a retry, for example to log it.
+	OnRetry func(request string, attempt int, wait time.Duration, err error)
+}
+
+// DefaultRetryPolicy is the RetryPolicy of new clients.
+var DefaultRetryPolicy = RetryPolicy{
+	Retries:    3,
+	Backoff:    200 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+	Jitter:     0.5,
+}
+
+// do runs fn, and runs it again as long as it fails with an error that
+// retryable accepts and retries are left. A wait asked for by the API server
+// with Retry-After is respected.
+func (p RetryPolicy) do(ctx context.Context, request string, retryable func(error) bool, fn func() error) error {
+	backoff := wait.Backoff{
+		Duration: p.Backoff,
+		Factor:   2,
+		Jitter:   p.Jitter,
+		Steps:    math.MaxInt32,
+		Cap:      p.MaxBackoff,
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > p.Retries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := backoff.Step()
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok && time.Duration(seconds)*time.Second > delay {
+			delay = time.Duration(seconds) * time.Second
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(request, attempt, delay, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// isTransient reports whether err is likely to go away when the request is
+// made again. Only idempotent requests may be retried on all such errors.
+func isTransient(err error) bool {
+	if apierrors.IsTooManyRequests(err) || apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+		apierrors.IsInternalError(err) || apierrors.IsServiceUnavailable(err) || apierrors.IsUnexpectedServerError(err) {
+		return true
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		return status.Status().Code >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isThrottled reports whether err means that the API server turned the request
+// away without handling it. Requests that are not idempotent may be retried then.
+func isThrottled(err error) bool {
+	return apierrors.IsTooManyRequests(err)
+}
@@ -0,0 +1,117 @@
+package tbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var secretsResource = schema.GroupResource{Resource: "secrets"}
+
+// failFirst makes the fake cluster fail the first n requests with verb on
+// secrets with err, and returns a pointer to the number of such requests.
+func failFirst(clientSet *fake.Clientset, verb string, n int, err error) *int {
+	calls := 0
+	clientSet.PrependReactor(verb, "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		if calls <= n {
+			return true, nil, err
+		}
+		return false, nil, nil
+	})
+	return &calls
+}
+
+// newRetryingClient returns a test client that retries quickly and records every retry.
+func newRetryingClient(t *testing.T, retries int) (*Client, *fake.Clientset, *[]string) {
+	client, clientSet := newTestClient()
+	var logged []string
+	client.Retry = RetryPolicy{
+		Retries:    retries,
+		Backoff:    time.Millisecond,
+		MaxBackoff: 4 * time.Millisecond,
+		Jitter:     0.5,
+		OnRetry: func(request string, attempt int, wait time.Duration, err error) {
+			assert.True(t, wait >= time.Millisecond && wait <= 6*time.Millisecond, "unexpected wait %v", wait)
+			logged = append(logged, request)
+		},
+	}
+	return client, clientSet, &logged
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"throttled", apierrors.NewTooManyRequests("slow down", 0)},
+		{"server timeout", apierrors.NewServerTimeout(secretsResource, "list", 0)},
+		{"internal error", apierrors.NewInternalError(context.DeadlineExceeded)},
+		{"unavailable", apierrors.NewServiceUnavailable("restarting")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, clientSet, logged := newRetryingClient(t, 3)
+			calls := failFirst(clientSet, "list", 2, tt.err)
+
+			_, err := client.ListSecrets(context.Background(), "team-a")
+			assert.Nil(t, err)
+			assert.Equal(t, 3, *calls)
+			assert.Equal(t, []string{"list secrets in team-a", "list secrets in team-a"}, *logged)
+		})
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	client, clientSet, _ := newRetryingClient(t, 2)
+	calls := failFirst(clientSet, "get", 10, apierrors.NewServiceUnavailable("down"))
+
+	_, err := client.GetSecret(context.Background(), "team-a", "my-app-default")
+	assert.True(t, apierrors.IsServiceUnavailable(err))
+	assert.Equal(t, 3, *calls)
+}
+
+func TestRetryNotOnPermanentErrors(t *testing.T) {
+	client, clientSet, logged := newRetryingClient(t, 3)
+	calls := failFirst(clientSet, "get", 10, apierrors.NewNotFound(secretsResource, "my-app-default"))
+
+	_, err := client.GetSecret(context.Background(), "team-a", "my-app-default")
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Equal(t, 1, *calls)
+	assert.Empty(t, *logged)
+}
+
+func TestRetryCreateOnlyWhenThrottled(t *testing.T) {
+	// The API server may have created the secret before failing, so creating
+	// it again is only safe when the request was turned away.
+	client, clientSet, _ := newRetryingClient(t, 3)
+	calls := failFirst(clientSet, "create", 1, apierrors.NewInternalError(context.DeadlineExceeded))
+	_, err := client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{Name: "my-app"})
+	assert.True(t, apierrors.IsInternalError(err))
+	assert.Equal(t, 1, *calls)
+
+	client, clientSet, _ = newRetryingClient(t, 3)
+	calls = failFirst(clientSet, "create", 1, apierrors.NewTooManyRequests("slow down", 0))
+	_, err = client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{Name: "my-app"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, *calls)
+}
+
+func TestRetryCanceled(t *testing.T) {
+	client, clientSet, _ := newRetryingClient(t, 3)
+	client.Retry.Backoff = time.Hour
+	client.Retry.MaxBackoff = time.Hour
+	failFirst(clientSet, "list", 10, apierrors.NewServiceUnavailable("down"))
+	ctx, cancel := context.WithCancel(context.Background())
+	client.Retry.OnRetry = func(string, int, time.Duration, error) { cancel() }
+
+	_, err := client.ListSecrets(ctx, "team-a")
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,95 @@
+package tbac
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Annotations of the rotation policy of a secret.
+const (
+	// AnnotationRotationPeriod holds how often the keys of a secret must be
+	// rotated, such as "90d".
+	AnnotationRotationPeriod = "tbac.bisnode.com/rotation-period"
+	// AnnotationLastRotated holds a JSON object with the time each key was
+	// last given a new value.
+	AnnotationLastRotated = "tbac.bisnode.com/last-rotated"
+)
+
+// FormatPeriod formats a rotation period in days when it is a whole number
+// of days, and as a time.Duration otherwise.
+func FormatPeriod(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+// KeyLastRotated returns when a key was last given a new value. Keys written
+// before rotation was tracked fall back to the creation time of the secret.
+func (s *Secret) KeyLastRotated(key string) (time.Time, error) {
+	if rotated, found := s.LastRotated[key]; found {
+		return ParseTimestamp(rotated)
+	}
+	return ParseTimestamp(s.Created)
+}
+
+// RotationDue returns the keys of a secret with a rotation period that were
+// not rotated within it, sorted. Keys without a known rotation time are due.
+func (s *Secret) RotationDue(now time.Time) []string {
+	if s.RotationPeriod <= 0 {
+		return nil
+	}
+	var due []string
+	for key := range s.Data {
+		rotated, err := s.KeyLastRotated(key)
+		if err != nil || now.Sub(rotated) >= s.RotationPeriod {
+			due = append(due, key)
+		}
+	}
+	sort.Strings(due)
+	return due
+}
+
+// lastRotatedAnnotation returns the AnnotationLastRotated annotation of a
+// secret whose data changes from old to data. Keys with a new or changed value
+// get timestamp, removed keys are dropped and other keys keep their time.
+func lastRotatedAnnotation(annotations map[string]string, old, data map[string][]byte, timestamp string) (string, error) {
+	lastRotated := parseLastRotated(annotations[AnnotationLastRotated])
+	updated := make(map[string]string, len(data))
+	for k, v := range data {
+		if prev, found := old[k]; found && string(prev) == string(v) {
+			if t, found := lastRotated[k]; found {
+				updated[k] = t
+			}
+			continue
+		}
+		updated[k] = timestamp
+	}
+	b, err := json.Marshal(updated)
+	return string(b), err
+}
+
+// parseLastRotated parses the AnnotationLastRotated annotation. An invalid
+// annotation counts as no known rotation times.
+func parseLastRotated(annotation string) map[string]string {
+	lastRotated := map[string]string{}
+	if annotation != "" {
+		_ = json.Unmarshal([]byte(annotation), &lastRotated)
+	}
+	return lastRotated
+}
+
+// parseRotationPeriod parses the AnnotationRotationPeriod annotation. An
+// invalid or missing annotation counts as no rotation period.
+func parseRotationPeriod(annotation string) time.Duration {
+	if annotation == "" {
+		return 0
+	}
+	period, err := ParseDuration(annotation)
+	if err != nil {
+		return 0
+	}
+	return period
+}
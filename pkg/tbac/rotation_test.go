@@ -0,0 +1,102 @@
+package tbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuration(t *testing.T) {
+	d, err := ParseDuration("90d")
+	assert.Nil(t, err)
+	assert.Equal(t, 90*24*time.Hour, d)
+	d, err = ParseDuration("1.5h")
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Minute, d)
+	_, err = ParseDuration("soon")
+	assert.NotNil(t, err)
+	_, err = ParseDuration("xd")
+	assert.NotNil(t, err)
+}
+
+func TestFormatPeriod(t *testing.T) {
+	assert.Equal(t, "90d", FormatPeriod(90*24*time.Hour))
+	assert.Equal(t, "36h0m0s", FormatPeriod(36*time.Hour))
+}
+
+func TestRotationTracking(t *testing.T) {
+	client, _ := newTestClient()
+	created, err := client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{
+		Name:           "my-app",
+		RotationPeriod: 90 * 24 * time.Hour,
+		Data:           map[string][]byte{"USERNAME": []byte("foo"), "PASSWORD": []byte("bar"), "TOKEN": []byte("baz")},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 90*24*time.Hour, created.RotationPeriod)
+	assert.Empty(t, created.RotationDue(client.Now()))
+
+	// Only keys given a new value are rotated.
+	client.Now = func() time.Time { return time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC) }
+	patched, result, err := client.PatchSecret(context.Background(), "team-a", PatchSecretOptions{
+		Name:       "my-app-default",
+		Data:       map[string][]byte{"USERNAME": []byte("foo"), "PASSWORD": []byte("new")},
+		RemoveData: []string{"TOKEN"},
+	})
+	assert.Nil(t, err)
+	assert.True(t, result.Changed())
+	assert.Equal(t, map[string]string{
+		"USERNAME": "2020-05-01 12:00:00 +0000 UTC",
+		"PASSWORD": "2020-06-01 12:00:00 +0000 UTC",
+	}, patched.LastRotated)
+	assert.Equal(t, 90*24*time.Hour, patched.RotationPeriod)
+
+	assert.Empty(t, patched.RotationDue(time.Date(2020, 7, 30, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, []string{"USERNAME"}, patched.RotationDue(time.Date(2020, 7, 30, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, []string{"PASSWORD", "USERNAME"}, patched.RotationDue(time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestPatchSecretRotationPeriod(t *testing.T) {
+	client, _ := newTestClient()
+	_, err := client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{
+		Name: "my-app",
+		Data: map[string][]byte{"PASSWORD": []byte("bar")},
+	})
+	assert.Nil(t, err)
+
+	// A new rotation period is written even though the data is the same.
+	patched, result, err := client.PatchSecret(context.Background(), "team-a", PatchSecretOptions{
+		Name:           "my-app-default",
+		Data:           map[string][]byte{"PASSWORD": []byte("bar")},
+		RotationPeriod: 30 * 24 * time.Hour,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, PatchResult{RotationPeriodChanged: true}, result)
+	assert.Equal(t, 30*24*time.Hour, patched.RotationPeriod)
+	assert.Equal(t, map[string]string{"PASSWORD": "2020-05-01 12:00:00 +0000 UTC"}, patched.LastRotated)
+
+	_, result, err = client.PatchSecret(context.Background(), "team-a", PatchSecretOptions{
+		Name:           "my-app-default",
+		Data:           map[string][]byte{"PASSWORD": []byte("bar")},
+		RotationPeriod: 30 * 24 * time.Hour,
+	})
+	assert.Nil(t, err)
+	assert.False(t, result.Changed())
+}
+
+func TestRotationDueUntracked(t *testing.T) {
+	// Keys of secrets written before rotation was tracked fall back to the
+	// creation time, and are due if that is unknown too.
+	s := &Secret{
+		RotationPeriod: 24 * time.Hour,
+		Created:        "2020-05-01 12:00:00 +0000 UTC",
+		Data:           map[string][]byte{"KEY": []byte("value")},
+	}
+	assert.Empty(t, s.RotationDue(time.Date(2020, 5, 2, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, []string{"KEY"}, s.RotationDue(time.Date(2020, 5, 2, 12, 0, 0, 0, time.UTC)))
+	s.Created = ""
+	assert.Equal(t, []string{"KEY"}, s.RotationDue(time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)))
+	s.RotationPeriod = 0
+	assert.Empty(t, s.RotationDue(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
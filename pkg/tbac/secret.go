@@ -0,0 +1,361 @@
+package tbac
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Secret is a tbac secret.
+type Secret struct {
+	Namespace    string
+	Name         string
+	App          string
+	Container    string
+	Type         v1.SecretType
+	Sandbox      bool
+	Created      string
+	LastModified string
+	// Checksum is the checksum of Data when the secret was last written by tbac.
+	Checksum string
+	// RotationPeriod is how often the keys must be rotated. Zero means never.
+	RotationPeriod time.Duration
+	// LastRotated holds when each key was last given a new value.
+	LastRotated map[string]string
+	// Expires is when the secret expires, or empty if it does not.
+	Expires string
+	// Generated holds how the generated keys were generated.
+	Generated map[string]GenerateSpec
+	Data      map[string][]byte
+}
+
+// CreateSecretOptions describes a secret to create.
+type CreateSecretOptions struct {
+	// Name of the secret, without the container suffix.
+	Name string
+	// Container the secret is for. Defaults to "default".
+	Container string
+	// App label of the secret. Defaults to Name.
+	App string
+	// Type of the secret. Defaults to Opaque.
+	Type v1.SecretType
+	// RotationPeriod is how often the keys must be rotated. Zero means never.
+	RotationPeriod time.Duration
+	// TTL is how long the secret lives before it expires. Zero means forever.
+	TTL time.Duration
+	// Overwrite replaces an existing secret of the same name instead of
+	// failing with AlreadyExists.
+	Overwrite bool
+	// Generated holds the specs of the keys of Data that were generated.
+	Generated []GenerateSpec
+	Data      map[string][]byte
+}
+
+// secretTypes are the types of secrets managed by tbac.
+var secretTypes = map[v1.SecretType]bool{
+	v1.SecretTypeOpaque:           true,
+	v1.SecretTypeTLS:              true,
+	v1.SecretTypeDockerConfigJson: true,
+}
+
+// PatchSecretOptions describes changes to an existing secret.
+type PatchSecretOptions struct {
+	// Name of the secret, including the container suffix.
+	Name string
+	// Data to add or update.
+	Data map[string][]byte
+	// RemoveData holds keys to remove.
+	RemoveData []string
+	// RotationPeriod sets how often the keys must be rotated. Zero leaves
+	// the rotation period as it is.
+	RotationPeriod time.Duration
+	// Generated holds the specs of the keys of Data that were generated.
+	Generated []GenerateSpec
+}
+
+// PatchResult tells what PatchSecret changed.
+type PatchResult struct {
+	// DataChanged is set when keys were added, given a new value or removed.
+	DataChanged bool
+	// RotationPeriodChanged is set when the rotation period was changed.
+	RotationPeriodChanged bool
+}
+
+// Changed reports whether the secret was written at all.
+func (r PatchResult) Changed() bool {
+	return r.DataChanged || r.RotationPeriodChanged
+}
+
+// RecreateError is returned by PatchSecret when a secret was deleted to remove
+// keys but could not be created again. Original holds the deleted secret.
+type RecreateError struct {
+	Original    *v1.Secret
+	Err         error
+	RollbackErr error
+}
+
+// Error implements the error interface.
+func (e *RecreateError) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("secret %v was removed and could not be recreated (%v) nor rolled back (%v)",
+			e.Original.Name, e.Err, e.RollbackErr)
+	}
+	return fmt.Sprintf("secret recreation failed and was rolled back: %v", e.Err)
+}
+
+// Unwrap returns the error of the recreation.
+func (e *RecreateError) Unwrap() error {
+	return e.Err
+}
+
+// CreateSecret creates a secret named after the secret and its container.
+func (c *Client) CreateSecret(ctx context.Context, namespace string, o CreateSecretOptions) (*Secret, error) {
+	container := o.Container
+	if container == "" {
+		container = "default"
+	}
+	app := o.App
+	if app == "" {
+		app = o.Name
+	}
+	secretType := o.Type
+	if secretType == "" {
+		secretType = v1.SecretTypeOpaque
+	}
+
+	lastRotated, err := lastRotatedAnnotation(nil, nil, o.Data, c.timestamp())
+	if err != nil {
+		return nil, err
+	}
+	generated, err := generatedAnnotation(nil, o.Data, o.Data, o.Generated)
+	if err != nil {
+		return nil, err
+	}
+
+	newSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      o.Name + "-" + container,
+			Namespace: namespace,
+			Labels: map[string]string{
+				LabelApp:       app,
+				LabelContainer: container,
+				LabelSandbox:   fmt.Sprintf("%v", strings.HasSuffix(namespace, "-sandbox")),
+			},
+			Annotations: map[string]string{
+				AnnotationLastModified: c.timestamp(),
+				AnnotationTimeCreated:  c.timestamp(),
+				AnnotationChecksum:     Checksum(o.Data),
+				AnnotationLastRotated:  lastRotated,
+			},
+		},
+		Type: secretType,
+		Data: o.Data,
+	}
+	if o.RotationPeriod > 0 {
+		newSecret.Annotations[AnnotationRotationPeriod] = FormatPeriod(o.RotationPeriod)
+	}
+	if o.TTL > 0 {
+		newSecret.Annotations[AnnotationExpires] = Timestamp(c.Now().Add(o.TTL))
+	}
+	if len(o.Generated) > 0 {
+		newSecret.Annotations[AnnotationGenerated] = generated
+	}
+
+	var created *v1.Secret
+	err = c.Retry.do(ctx, "create secret "+namespace+"/"+newSecret.Name, isThrottled, func() (err error) {
+		created, err = c.clientSet.CoreV1().Secrets(namespace).Create(ctx, newSecret, metav1.CreateOptions{})
+		return err
+	})
+	if apierrors.IsAlreadyExists(err) && o.Overwrite {
+		err = c.Retry.do(ctx, "update secret "+namespace+"/"+newSecret.Name, isThrottled, func() (err error) {
+			created, err = c.clientSet.CoreV1().Secrets(namespace).Update(ctx, newSecret, metav1.UpdateOptions{})
+			return err
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return secretFromAPI(created), nil
+}
+
+// PatchSecret updates an already existing secret with patched content. It
+// reports whether the data and the rotation period changed. A patch that
+// would change neither is not written at all, so the last-modified annotation
+// is left as it is. Keys given a new value get a new last-rotated time.
+//
+// When keys are removed, the secret is first removed from Kubernetes and then
+// recreated without the unwanted keys. If recreation fails the original secret
+// is restored, and a *RecreateError is returned.
+func (c *Client) PatchSecret(ctx context.Context, namespace string, o PatchSecretOptions) (*Secret, PatchResult, error) {
+	if len(o.RemoveData) == 0 && len(o.Data) == 0 && o.RotationPeriod <= 0 {
+		return nil, PatchResult{}, fmt.Errorf("no patch data provided")
+	}
+
+	secretsClient := c.clientSet.CoreV1().Secrets(namespace)
+
+	var originalSecret *v1.Secret
+	err := c.Retry.do(ctx, "get secret "+namespace+"/"+o.Name, isTransient, func() (err error) {
+		originalSecret, err = secretsClient.Get(ctx, o.Name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, PatchResult{}, err
+	}
+	// Resource version cannot be defined in request to Kubernetes.
+	originalSecret.ResourceVersion = ""
+	patchSecret := originalSecret.DeepCopy()
+
+	// If data to remove, remove from the patch version
+	removed := false
+	for _, d := range o.RemoveData {
+		if _, found := patchSecret.Data[d]; found {
+			delete(patchSecret.Data, d)
+			removed = true
+		}
+	}
+	if patchSecret.Data == nil {
+		patchSecret.Data = make(map[string][]byte)
+	}
+	for k, v := range o.Data {
+		patchSecret.Data[k] = v
+	}
+	period := ""
+	if o.RotationPeriod > 0 {
+		period = FormatPeriod(o.RotationPeriod)
+	}
+	result := PatchResult{
+		DataChanged:           Checksum(patchSecret.Data) != Checksum(originalSecret.Data),
+		RotationPeriodChanged: period != "" && period != originalSecret.Annotations[AnnotationRotationPeriod],
+	}
+	if !result.Changed() {
+		return secretFromAPI(originalSecret), result, nil
+	}
+
+	if removed {
+		if err := secretsClient.Delete(ctx, o.Name, metav1.DeleteOptions{}); err != nil {
+			return nil, PatchResult{}, err
+		}
+		// Once deleted, the secret must come back even if ctx is canceled.
+		recreateCtx := context.WithoutCancel(ctx)
+		patchSecret, err = secretsClient.Create(recreateCtx, patchSecret, metav1.CreateOptions{})
+		// If delete was successful but recreate not, roll back to the original secret.
+		if err != nil {
+			_, rollbackErr := secretsClient.Create(recreateCtx, originalSecret, metav1.CreateOptions{})
+			return nil, PatchResult{}, &RecreateError{Original: originalSecret, Err: err, RollbackErr: rollbackErr}
+		}
+		patchSecret.ResourceVersion = ""
+	}
+
+	if patchSecret.Annotations == nil {
+		patchSecret.Annotations = make(map[string]string)
+	}
+	patchSecret.Annotations[AnnotationLastModified] = c.timestamp()
+	patchSecret.Annotations[AnnotationChecksum] = Checksum(patchSecret.Data)
+	patchSecret.Annotations[AnnotationLastRotated], err = lastRotatedAnnotation(originalSecret.Annotations, originalSecret.Data, patchSecret.Data, c.timestamp())
+	if err != nil {
+		return nil, PatchResult{}, err
+	}
+	if result.RotationPeriodChanged {
+		patchSecret.Annotations[AnnotationRotationPeriod] = period
+	}
+	// The patch merges annotations, so a known annotation is emptied rather than removed.
+	if _, found := originalSecret.Annotations[AnnotationGenerated]; found || len(o.Generated) > 0 {
+		patchSecret.Annotations[AnnotationGenerated], err = generatedAnnotation(originalSecret.Annotations, patchSecret.Data, o.Data, o.Generated)
+		if err != nil {
+			return nil, PatchResult{}, err
+		}
+	}
+
+	patch, err := json.Marshal(patchSecret)
+	if err != nil {
+		return nil, PatchResult{}, err
+	}
+	// The patch holds the whole secret, so applying it twice does no harm.
+	var patched *v1.Secret
+	err = c.Retry.do(ctx, "patch secret "+namespace+"/"+o.Name, isTransient, func() (err error) {
+		patched, err = secretsClient.Patch(ctx, o.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, PatchResult{}, err
+	}
+	return secretFromAPI(patched), result, nil
+}
+
+// DeleteSecret deletes a secret.
+func (c *Client) DeleteSecret(ctx context.Context, namespace, name string) error {
+	return c.Retry.do(ctx, "delete secret "+namespace+"/"+name, isThrottled, func() error {
+		return c.clientSet.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	})
+}
+
+// GetSecret returns a secret.
+func (c *Client) GetSecret(ctx context.Context, namespace, name string) (*Secret, error) {
+	var secret *v1.Secret
+	err := c.Retry.do(ctx, "get secret "+namespace+"/"+name, isTransient, func() (err error) {
+		secret, err = c.clientSet.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secretFromAPI(secret), nil
+}
+
+// ListSecrets returns the Opaque, TLS and docker registry secrets in a namespace.
+func (c *Client) ListSecrets(ctx context.Context, namespace string) ([]Secret, error) {
+	var secretList *v1.SecretList
+	err := c.Retry.do(ctx, "list secrets in "+namespace, isTransient, func() (err error) {
+		secretList, err = c.clientSet.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	secrets := make([]Secret, 0, len(secretList.Items))
+	for i := range secretList.Items {
+		// Field selectors cannot select several types, so they are filtered here.
+		secret := secretFromAPI(&secretList.Items[i])
+		if !secretTypes[secret.Type] {
+			continue
+		}
+		secrets = append(secrets, *secret)
+	}
+	return secrets, nil
+}
+
+// secretFromAPI converts a Kubernetes secret to a Secret.
+func secretFromAPI(s *v1.Secret) *Secret {
+	data := make(map[string][]byte, len(s.Data))
+	for k, v := range s.Data {
+		data[k] = v
+	}
+	// The API server defaults the type, but secrets from elsewhere may lack it.
+	secretType := s.Type
+	if secretType == "" {
+		secretType = v1.SecretTypeOpaque
+	}
+	return &Secret{
+		Namespace:      s.Namespace,
+		Name:           s.Name,
+		App:            s.Labels[LabelApp],
+		Container:      s.Labels[LabelContainer],
+		Type:           secretType,
+		Sandbox:        s.Labels[LabelSandbox] == "true",
+		Created:        s.Annotations[AnnotationTimeCreated],
+		LastModified:   s.Annotations[AnnotationLastModified],
+		Checksum:       s.Annotations[AnnotationChecksum],
+		RotationPeriod: parseRotationPeriod(s.Annotations[AnnotationRotationPeriod]),
+		LastRotated:    parseLastRotated(s.Annotations[AnnotationLastRotated]),
+		Expires:        s.Annotations[AnnotationExpires],
+		Generated:      parseGenerated(s.Annotations[AnnotationGenerated]),
+		Data:           data,
+	}
+}
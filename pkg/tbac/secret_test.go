@@ -0,0 +1,149 @@
+package tbac
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestClient returns a Client on a fake clientSet with a fixed clock.
+func newTestClient() (*Client, *fake.Clientset) {
+	clientSet := fake.NewSimpleClientset()
+	client := NewClient(clientSet)
+	client.Now = func() time.Time { return time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC) }
+	return client, clientSet
+}
+
+func TestCreateAndGetSecret(t *testing.T) {
+	client, _ := newTestClient()
+
+	created, err := client.CreateSecret(context.Background(), "team-a-sandbox", CreateSecretOptions{
+		Name: "my-app",
+		Data: map[string][]byte{"USERNAME": []byte("foo")},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "my-app-default", created.Name)
+
+	secret, err := client.GetSecret(context.Background(), "team-a-sandbox", "my-app-default")
+	assert.Nil(t, err)
+	assert.Equal(t, &Secret{
+		Namespace:    "team-a-sandbox",
+		Name:         "my-app-default",
+		App:          "my-app",
+		Container:    "default",
+		Type:         v1.SecretTypeOpaque,
+		Sandbox:      true,
+		Created:      "2020-05-01 12:00:00 +0000 UTC",
+		LastModified: "2020-05-01 12:00:00 +0000 UTC",
+		Checksum:     Checksum(map[string][]byte{"USERNAME": []byte("foo")}),
+		LastRotated:  map[string]string{"USERNAME": "2020-05-01 12:00:00 +0000 UTC"},
+		Data:         map[string][]byte{"USERNAME": []byte("foo")},
+	}, secret)
+
+	_, err = client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{Name: "sidecar", Container: "opa", App: "my-app"})
+	assert.Nil(t, err)
+	secrets, err := client.ListSecrets(context.Background(), "team-a")
+	assert.Nil(t, err)
+	assert.Len(t, secrets, 1)
+	assert.Equal(t, "sidecar-opa", secrets[0].Name)
+	assert.Equal(t, "my-app", secrets[0].App)
+	assert.False(t, secrets[0].Sandbox)
+}
+
+func TestPatchSecret(t *testing.T) {
+	client, _ := newTestClient()
+	_, err := client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{
+		Name: "my-app",
+		Data: map[string][]byte{"USERNAME": []byte("foo"), "PASSWORD": []byte("bar")},
+	})
+	assert.Nil(t, err)
+
+	_, _, err = client.PatchSecret(context.Background(), "team-a", PatchSecretOptions{Name: "my-app-default"})
+	assert.NotNil(t, err)
+
+	patched, result, err := client.PatchSecret(context.Background(), "team-a", PatchSecretOptions{
+		Name:       "my-app-default",
+		Data:       map[string][]byte{"PASSWORD": []byte("baz")},
+		RemoveData: []string{"USERNAME"},
+	})
+	assert.Nil(t, err)
+	assert.True(t, result.Changed())
+	assert.Equal(t, map[string][]byte{"PASSWORD": []byte("baz")}, patched.Data)
+	assert.Equal(t, Checksum(patched.Data), patched.Checksum)
+}
+
+func TestPatchSecretUnchanged(t *testing.T) {
+	client, clientSet := newTestClient()
+	_, err := client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{
+		Name: "my-app",
+		Data: map[string][]byte{"USERNAME": []byte("foo")},
+	})
+	assert.Nil(t, err)
+	clientSet.ClearActions()
+	client.Now = func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	secret, result, err := client.PatchSecret(context.Background(), "team-a", PatchSecretOptions{
+		Name:       "my-app-default",
+		Data:       map[string][]byte{"USERNAME": []byte("foo")},
+		RemoveData: []string{"MISSING"},
+	})
+	assert.Nil(t, err)
+	assert.False(t, result.Changed())
+	assert.Equal(t, "2020-05-01 12:00:00 +0000 UTC", secret.LastModified)
+	// Only the secret was read.
+	assert.Len(t, clientSet.Actions(), 1)
+	assert.Equal(t, "get", clientSet.Actions()[0].GetVerb())
+}
+
+func TestChecksum(t *testing.T) {
+	a := Checksum(map[string][]byte{"A": []byte("1"), "B": []byte("2")})
+	assert.Equal(t, a, Checksum(map[string][]byte{"B": []byte("2"), "A": []byte("1")}))
+	assert.NotEqual(t, a, Checksum(map[string][]byte{"A": []byte("1B"), "": []byte("2")}))
+	assert.Equal(t, Checksum(nil), Checksum(map[string][]byte{}))
+}
+
+func TestPatchSecretRollback(t *testing.T) {
+	client, clientSet := newTestClient()
+	_, err := client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{
+		Name: "my-app",
+		Data: map[string][]byte{"USERNAME": []byte("foo"), "PASSWORD": []byte("bar")},
+	})
+	assert.Nil(t, err)
+
+	// Fail only the recreation without the removed key.
+	failed := false
+	clientSet.PrependReactor("create", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if failed {
+			return false, nil, nil
+		}
+		failed = true
+		return true, nil, fmt.Errorf("boom")
+	})
+
+	_, _, err = client.PatchSecret(context.Background(), "team-a", PatchSecretOptions{Name: "my-app-default", RemoveData: []string{"USERNAME"}})
+	recreateErr, ok := err.(*RecreateError)
+	assert.True(t, ok)
+	assert.Nil(t, recreateErr.RollbackErr)
+
+	restored, err := clientSet.CoreV1().Secrets("team-a").Get(context.Background(), "my-app-default", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("foo"), restored.Data["USERNAME"])
+}
+
+func TestDeleteSecret(t *testing.T) {
+	client, _ := newTestClient()
+	_, err := client.CreateSecret(context.Background(), "team-a", CreateSecretOptions{Name: "my-app"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, client.DeleteSecret(context.Background(), "team-a", "my-app-default"))
+	_, err = client.GetSecret(context.Background(), "team-a", "my-app-default")
+	assert.NotNil(t, err)
+}
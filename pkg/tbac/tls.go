@@ -0,0 +1,128 @@
+package tbac
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Certificate describes the certificate of a TLS secret.
+type Certificate struct {
+	Subject string
+	// SANs holds the subject alternative names: DNS names, IP addresses,
+	// email addresses and URIs.
+	SANs      []string
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// ExpiresIn returns how long the certificate is valid after now. It is
+// negative once the certificate has expired.
+func (c *Certificate) ExpiresIn(now time.Time) time.Duration {
+	return c.NotAfter.Sub(now)
+}
+
+// CreateTLSSecretOptions describes a TLS secret to create.
+type CreateTLSSecretOptions struct {
+	// Name of the secret, without the container suffix.
+	Name string
+	// Container the secret is for. Defaults to "default".
+	Container string
+	// App label of the secret. Defaults to Name.
+	App string
+	// Cert holds the PEM encoded certificate chain, starting with the leaf.
+	Cert []byte
+	// Key holds the PEM encoded private key of the certificate.
+	Key []byte
+}
+
+// CreateTLSSecret creates a kubernetes.io/tls secret, after checking that the
+// certificate and key are valid PEM and that the key belongs to the certificate.
+func (c *Client) CreateTLSSecret(ctx context.Context, namespace string, o CreateTLSSecretOptions) (*Secret, error) {
+	if _, err := ValidateTLS(o.Cert, o.Key); err != nil {
+		return nil, err
+	}
+	return c.CreateSecret(ctx, namespace, CreateSecretOptions{
+		Name:      o.Name,
+		Container: o.Container,
+		App:       o.App,
+		Type:      v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey:       o.Cert,
+			v1.TLSPrivateKeyKey: o.Key,
+		},
+	})
+}
+
+// ValidateTLS checks that certPEM and keyPEM are a PEM encoded certificate and
+// its private key, and returns the certificate.
+func ValidateTLS(certPEM, keyPEM []byte) (*Certificate, error) {
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return nil, fmt.Errorf("invalid certificate or key: %w", err)
+	}
+	return ParseCertificate(certPEM)
+}
+
+// ParseCertificate returns the first certificate of a PEM encoded chain.
+func ParseCertificate(certPEM []byte) (*Certificate, error) {
+	certs, err := ParseCertificates(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM encoded certificate found")
+	}
+	return certs[0], nil
+}
+
+// ParseCertificates returns every certificate in PEM encoded data. Other PEM
+// blocks, such as private keys, are skipped.
+func ParseCertificates(data []byte) ([]*Certificate, error) {
+	var certs []*Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+		certs = append(certs, certificateFromX509(cert))
+	}
+}
+
+// Certificate returns the certificate of a TLS secret, or nil for other secrets.
+func (s *Secret) Certificate() (*Certificate, error) {
+	if s.Type != v1.SecretTypeTLS {
+		return nil, nil
+	}
+	return ParseCertificate(s.Data[v1.TLSCertKey])
+}
+
+// certificateFromX509 converts a parsed certificate to a Certificate.
+func certificateFromX509(cert *x509.Certificate) *Certificate {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return &Certificate{
+		Subject:   cert.Subject.String(),
+		SANs:      sans,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}
+}
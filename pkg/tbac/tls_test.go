@@ -0,0 +1,73 @@
+package tbac
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+// selfSigned returns a PEM encoded self-signed certificate for dnsName and its key.
+func selfSigned(t *testing.T, dnsName string, notAfter time.Time) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestCreateTLSSecret(t *testing.T) {
+	client, _ := newTestClient()
+	notAfter := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	cert, key := selfSigned(t, "my-app.example.com", notAfter)
+
+	created, err := client.CreateTLSSecret(context.Background(), "team-a", CreateTLSSecretOptions{Name: "my-app-tls", Cert: cert, Key: key})
+	assert.Nil(t, err)
+	assert.Equal(t, v1.SecretTypeTLS, created.Type)
+	assert.Equal(t, cert, created.Data[v1.TLSCertKey])
+
+	secrets, err := client.ListSecrets(context.Background(), "team-a")
+	assert.Nil(t, err)
+	assert.Len(t, secrets, 1)
+	certificate, err := secrets[0].Certificate()
+	assert.Nil(t, err)
+	assert.Equal(t, "CN=my-app.example.com", certificate.Subject)
+	assert.Equal(t, []string{"my-app.example.com", "10.0.0.1"}, certificate.SANs)
+	assert.Equal(t, 24*time.Hour, certificate.ExpiresIn(notAfter.Add(-24*time.Hour)))
+}
+
+func TestCreateTLSSecretInvalid(t *testing.T) {
+	client, _ := newTestClient()
+	cert, _ := selfSigned(t, "a.example.com", time.Now().Add(time.Hour))
+	_, otherKey := selfSigned(t, "b.example.com", time.Now().Add(time.Hour))
+
+	_, err := client.CreateTLSSecret(context.Background(), "team-a", CreateTLSSecretOptions{Name: "mismatch", Cert: cert, Key: otherKey})
+	assert.NotNil(t, err)
+	_, err = client.CreateTLSSecret(context.Background(), "team-a", CreateTLSSecretOptions{Name: "garbage", Cert: []byte("not a cert"), Key: otherKey})
+	assert.NotNil(t, err)
+
+	secrets, err := client.ListSecrets(context.Background(), "team-a")
+	assert.Nil(t, err)
+	assert.Empty(t, secrets)
+}
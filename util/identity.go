@@ -0,0 +1,220 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	login "github.com/Bisnode/kubectl-login/util"
+
+	"github.com/pkg/errors"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Identity sources, tried in the order given to WhoAmI.
+const (
+	// IdentityKubectlLogin reads the token cached by kubectl login.
+	IdentityKubectlLogin = "kubectl-login"
+	// IdentityToken reads the token of the kube config user, or of --token.
+	IdentityToken = "token"
+	// IdentityExec runs the exec credential plugin of the kube config user.
+	IdentityExec = "exec"
+	// IdentityReview asks the cluster with a SelfSubjectReview, or a
+	// TokenReview on clusters that do not serve SelfSubjectReviews.
+	IdentityReview = "review"
+)
+
+// DefaultIdentitySources is the order identity sources are tried in by default.
+var DefaultIdentitySources = []string{IdentityKubectlLogin, IdentityToken, IdentityExec, IdentityReview}
+
+// Identity is what identity sources know about the current user.
+type Identity struct {
+	// Context is the name of the kube config context.
+	Context string
+	// Config is the client configuration of the context, with kubectl flags applied.
+	Config *rest.Config
+	// ClientSet returns a clientSet for Config.
+	ClientSet func() (kubernetes.Interface, error)
+	// MatchPrefix selects the groups that are teams, and TrimPrefix is
+	// removed from them to get the team name.
+	MatchPrefix string
+	TrimPrefix  string
+}
+
+// IdentitySource returns the teams of the user. It returns false if it cannot
+// tell, for example when there is no token to read, so that the next source is tried.
+type IdentitySource func(ctx context.Context, id *Identity) (teams []string, ok bool, err error)
+
+// identitySources holds every known identity source by name.
+var identitySources = map[string]IdentitySource{
+	IdentityKubectlLogin: kubectlLoginSource,
+	IdentityToken:        tokenSource,
+	IdentityExec:         execSource,
+	IdentityReview:       reviewSource,
+}
+
+// resolve tries the named identity sources in order and returns the teams
+// from the first one that can tell. Errors of sources are only returned if
+// no source could tell.
+func (id *Identity) resolve(ctx context.Context, sources []string) ([]string, error) {
+	var errs []string
+	for _, name := range sources {
+		source, found := identitySources[name]
+		if !found {
+			return nil, fmt.Errorf("unknown identity source %q, must be one of %v", name, strings.Join(DefaultIdentitySources, ", "))
+		}
+		teams, ok, err := source(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", name, err))
+			continue
+		}
+		if ok {
+			return teams, nil
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to find your teams (%v)", strings.Join(errs, "; "))
+	}
+	return []string{}, nil
+}
+
+// teams returns the groups that are teams, with TrimPrefix removed.
+func (id *Identity) teams(groups []string) []string {
+	teams := []string{}
+	for _, g := range groups {
+		if strings.HasPrefix(g, id.MatchPrefix) {
+			teams = append(teams, strings.TrimPrefix(g, id.TrimPrefix))
+		}
+	}
+	return teams
+}
+
+// kubectlLoginSource reads the teams from the token cached by kubectl login.
+func kubectlLoginSource(_ context.Context, id *Identity) ([]string, bool, error) {
+	rawToken := login.ReadToken(id.Context)
+	if rawToken == "" {
+		return nil, false, nil
+	}
+	return login.ExtractTeams(login.JwtToIdentityClaims(rawToken)), true, nil
+}
+
+// tokenSource reads the teams from the groups claim of the bearer token of
+// the kube config user. Tokens that are not JWTs or have no groups claim
+// cannot tell.
+func tokenSource(_ context.Context, id *Identity) ([]string, bool, error) {
+	rawToken := id.Config.BearerToken
+	if rawToken == "" && id.Config.BearerTokenFile != "" {
+		raw, err := ioutil.ReadFile(id.Config.BearerTokenFile)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to read token file")
+		}
+		rawToken = strings.TrimSpace(string(raw))
+	}
+	groups, ok := jwtGroups(rawToken)
+	if !ok {
+		return nil, false, nil
+	}
+	return id.teams(groups), true, nil
+}
+
+// execSource runs the exec credential plugin of the kube config user and
+// reads the teams from the groups claim of the token it returns.
+func execSource(ctx context.Context, id *Identity) ([]string, bool, error) {
+	provider := id.Config.ExecProvider
+	if provider == nil {
+		return nil, false, nil
+	}
+	info, err := json.Marshal(map[string]interface{}{
+		"apiVersion": provider.APIVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]interface{}{"interactive": false},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	plugin := exec.CommandContext(ctx, provider.Command, provider.Args...)
+	plugin.Env = append(os.Environ(), "KUBERNETES_EXEC_INFO="+string(info))
+	for _, env := range provider.Env {
+		plugin.Env = append(plugin.Env, env.Name+"="+env.Value)
+	}
+	plugin.Stderr = os.Stderr
+	var out bytes.Buffer
+	plugin.Stdout = &out
+	if err := plugin.Run(); err != nil {
+		return nil, false, errors.Wrapf(err, "failed to run %v", provider.Command)
+	}
+
+	var credential struct {
+		Status struct {
+			Token string `json:"token"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &credential); err != nil {
+		return nil, false, errors.Wrapf(err, "failed to parse output of %v", provider.Command)
+	}
+	groups, ok := jwtGroups(credential.Status.Token)
+	if !ok {
+		return nil, false, nil
+	}
+	return id.teams(groups), true, nil
+}
+
+// reviewSource asks the cluster who the user is. This works with any way of
+// authenticating, but costs a request.
+func reviewSource(ctx context.Context, id *Identity) ([]string, bool, error) {
+	clientSet, err := id.ClientSet()
+	if err != nil {
+		return nil, false, err
+	}
+	review, err := clientSet.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err == nil {
+		return id.teams(review.Status.UserInfo.Groups), true, nil
+	}
+	if !apierrors.IsNotFound(err) || id.Config.BearerToken == "" {
+		return nil, false, err
+	}
+
+	// Clusters before Kubernetes 1.28 do not serve SelfSubjectReviews.
+	tokenReview, err := clientSet.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: id.Config.BearerToken},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	if !tokenReview.Status.Authenticated {
+		return nil, false, nil
+	}
+	return id.teams(tokenReview.Status.User.Groups), true, nil
+}
+
+// jwtGroups returns the groups claim of a JWT. The signature is not verified,
+// the API server does that. It returns false if rawToken is not a JWT or has
+// no groups, as some issuers leave them out of the token.
+func jwtGroups(rawToken string) ([]string, bool) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, false
+	}
+	var claims struct {
+		Groups []string `json:"groups"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, false
+	}
+	return claims.Groups, len(claims.Groups) > 0
+}
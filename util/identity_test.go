@@ -0,0 +1,123 @@
+package util
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// fakeJWT returns an unsigned JWT with the given groups claim.
+func fakeJWT(groups ...string) string {
+	payload, _ := json.Marshal(map[string]interface{}{"sub": "jane", "groups": groups})
+	return "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+}
+
+// reviewClientSet returns a fake clientSet answering SelfSubjectReviews with groups.
+func reviewClientSet(groups ...string) func() (kubernetes.Interface, error) {
+	clientSet := fake.NewSimpleClientset()
+	clientSet.PrependReactor("create", "selfsubjectreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := &authenticationv1.SelfSubjectReview{}
+		review.Status.UserInfo.Groups = groups
+		return true, review, nil
+	})
+	return func() (kubernetes.Interface, error) { return clientSet, nil }
+}
+
+func newIdentity(config *rest.Config, clientSet func() (kubernetes.Interface, error)) *Identity {
+	return &Identity{
+		Context:     "test",
+		Config:      config,
+		ClientSet:   clientSet,
+		MatchPrefix: "sec-tbac-team-",
+		TrimPrefix:  "sec-tbac-",
+	}
+}
+
+func TestJwtGroups(t *testing.T) {
+	groups, ok := jwtGroups(fakeJWT("sec-tbac-team-a", "other"))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"sec-tbac-team-a", "other"}, groups)
+
+	_, ok = jwtGroups("not-a-jwt")
+	assert.False(t, ok)
+
+	_, ok = jwtGroups(fakeJWT())
+	assert.False(t, ok)
+	_, ok = jwtGroups("e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"jane"}`)) + ".c2ln")
+	assert.False(t, ok)
+}
+
+func TestTokenSource(t *testing.T) {
+	id := newIdentity(&rest.Config{BearerToken: fakeJWT("sec-tbac-team-a", "developers")}, nil)
+	teams, ok, err := tokenSource(context.Background(), id)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"team-a"}, teams)
+
+	// Static tokens that are not JWTs cannot tell.
+	_, ok, err = tokenSource(context.Background(), newIdentity(&rest.Config{BearerToken: "static"}, nil))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestExecSource(t *testing.T) {
+	credential := fmt.Sprintf(`{"kind":"ExecCredential","status":{"token":"%v"}}`, fakeJWT("sec-tbac-team-b"))
+	id := newIdentity(&rest.Config{ExecProvider: &clientcmdapi.ExecConfig{
+		Command:    "sh",
+		Args:       []string{"-c", "echo '" + credential + "'"},
+		APIVersion: "client.authentication.k8s.io/v1",
+	}}, nil)
+	teams, ok, err := execSource(context.Background(), id)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"team-b"}, teams)
+
+	_, ok, err = execSource(context.Background(), newIdentity(&rest.Config{}, nil))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestReviewSource(t *testing.T) {
+	id := newIdentity(&rest.Config{}, reviewClientSet("system:authenticated", "sec-tbac-team-c"))
+	teams, ok, err := reviewSource(context.Background(), id)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"team-c"}, teams)
+}
+
+func TestResolveOrder(t *testing.T) {
+	id := newIdentity(&rest.Config{BearerToken: fakeJWT("sec-tbac-team-a")}, reviewClientSet("sec-tbac-team-c"))
+
+	teams, err := id.resolve(context.Background(), []string{IdentityToken, IdentityReview})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"team-a"}, teams)
+
+	teams, err = id.resolve(context.Background(), []string{IdentityReview, IdentityToken})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"team-c"}, teams)
+
+	// Tokens without a groups claim fall through to the review.
+	id.Config.BearerToken = "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"jane"}`)) + ".c2ln"
+	teams, err = id.resolve(context.Background(), []string{IdentityToken, IdentityReview})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"team-c"}, teams)
+
+	// Sources that cannot tell are skipped.
+	teams, err = id.resolve(context.Background(), []string{IdentityExec, IdentityReview})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"team-c"}, teams)
+
+	_, err = id.resolve(context.Background(), []string{"carrier-pigeon"})
+	assert.NotNil(t, err)
+}
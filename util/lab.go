@@ -0,0 +1,168 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+// LabIdentity is a simulated user used in lab mode.
+type LabIdentity struct {
+	User   string    `json:"user"`
+	Teams  []string  `json:"teams"`
+	Expiry time.Time `json:"expiry,omitempty"`
+}
+
+// DefaultLabIdentity is used in lab mode when no identity file is given.
+var DefaultLabIdentity = LabIdentity{
+	User:  "lab-user",
+	Teams: []string{"team-platform"},
+}
+
+// labState is what is persisted between runs of a lab cluster. Workloads and
+// service accounts are kept so that commands using them, such as --restart,
+// can be practiced by adding them to the file.
+type labState struct {
+	Secrets         []v1.Secret            `json:"secrets"`
+	ServiceAccounts []v1.ServiceAccount    `json:"serviceAccounts,omitempty"`
+	Deployments     []appsv1.Deployment    `json:"deployments,omitempty"`
+	StatefulSets    []appsv1.StatefulSet   `json:"statefulSets,omitempty"`
+	CronJobs        []batchv1.CronJob      `json:"cronJobs,omitempty"`
+	Jobs            []batchv1.Job          `json:"jobs,omitempty"`
+	ReplicaSets     []appsv1.ReplicaSet    `json:"replicaSets,omitempty"`
+	Pods            []v1.Pod               `json:"pods,omitempty"`
+	Ingresses       []networkingv1.Ingress `json:"ingresses,omitempty"`
+}
+
+// Expired reports whether the identity has an expiry that has passed.
+func (i *LabIdentity) Expired(now time.Time) bool {
+	return !i.Expiry.IsZero() && now.After(i.Expiry)
+}
+
+// LoadLabIdentity reads a simulated identity from a YAML or JSON file.
+func LoadLabIdentity(path string) (*LabIdentity, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read lab identity")
+	}
+	identity := &LabIdentity{}
+	if err := yaml.Unmarshal(raw, identity); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse lab identity %v", path)
+	}
+	return identity, nil
+}
+
+// LoadLabCluster returns a fake clientSet with the resources stored in path.
+// A missing file gives an empty cluster.
+func LoadLabCluster(path string) (*fake.Clientset, error) {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fake.NewSimpleClientset(), nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read lab cluster")
+	}
+
+	state := &labState{}
+	if err := yaml.Unmarshal(raw, state); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse lab cluster %v", path)
+	}
+	var objects []runtime.Object
+	for i := range state.Secrets {
+		objects = append(objects, &state.Secrets[i])
+	}
+	for i := range state.ServiceAccounts {
+		objects = append(objects, &state.ServiceAccounts[i])
+	}
+	for i := range state.Deployments {
+		objects = append(objects, &state.Deployments[i])
+	}
+	for i := range state.StatefulSets {
+		objects = append(objects, &state.StatefulSets[i])
+	}
+	for i := range state.CronJobs {
+		objects = append(objects, &state.CronJobs[i])
+	}
+	for i := range state.Jobs {
+		objects = append(objects, &state.Jobs[i])
+	}
+	for i := range state.ReplicaSets {
+		objects = append(objects, &state.ReplicaSets[i])
+	}
+	for i := range state.Pods {
+		objects = append(objects, &state.Pods[i])
+	}
+	for i := range state.Ingresses {
+		objects = append(objects, &state.Ingresses[i])
+	}
+	return fake.NewSimpleClientset(objects...), nil
+}
+
+// SaveLabCluster stores the resources of a lab cluster in path.
+func SaveLabCluster(ctx context.Context, clientSet kubernetes.Interface, path string) error {
+	all := metav1.NamespaceAll
+	list := metav1.ListOptions{}
+	state := &labState{}
+	secrets, err := clientSet.CoreV1().Secrets(all).List(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, "failed to list lab secrets")
+	}
+	state.Secrets = secrets.Items
+	serviceAccounts, err := clientSet.CoreV1().ServiceAccounts(all).List(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, "failed to list lab service accounts")
+	}
+	state.ServiceAccounts = serviceAccounts.Items
+	deployments, err := clientSet.AppsV1().Deployments(all).List(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, "failed to list lab deployments")
+	}
+	state.Deployments = deployments.Items
+	statefulSets, err := clientSet.AppsV1().StatefulSets(all).List(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, "failed to list lab statefulsets")
+	}
+	state.StatefulSets = statefulSets.Items
+	cronJobs, err := clientSet.BatchV1().CronJobs(all).List(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, "failed to list lab cronjobs")
+	}
+	state.CronJobs = cronJobs.Items
+	jobs, err := clientSet.BatchV1().Jobs(all).List(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, "failed to list lab jobs")
+	}
+	state.Jobs = jobs.Items
+	replicaSets, err := clientSet.AppsV1().ReplicaSets(all).List(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, "failed to list lab replicasets")
+	}
+	state.ReplicaSets = replicaSets.Items
+	pods, err := clientSet.CoreV1().Pods(all).List(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, "failed to list lab pods")
+	}
+	state.Pods = pods.Items
+	ingresses, err := clientSet.NetworkingV1().Ingresses(all).List(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, "failed to list lab ingresses")
+	}
+	state.Ingresses = ingresses.Items
+	raw, err := yaml.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "failed to serialize lab cluster")
+	}
+	return errors.Wrap(ioutil.WriteFile(path, raw, 0600), "failed to write lab cluster")
+}
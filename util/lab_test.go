@@ -0,0 +1,81 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadLabIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl-tbac")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "identity.yaml")
+	content := "user: jane\nteams:\n- team-a\n- team-b\nexpiry: 2020-01-01T00:00:00Z\n"
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	identity, err := LoadLabIdentity(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "jane", identity.User)
+	assert.Equal(t, []string{"team-a", "team-b"}, identity.Teams)
+	assert.True(t, identity.Expired(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, identity.Expired(time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, DefaultLabIdentity.Expired(time.Now()))
+}
+
+func TestLabClusterRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl-tbac")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cluster.yaml")
+
+	clientSet, err := LoadLabCluster(path)
+	assert.Nil(t, err)
+	_, err = clientSet.CoreV1().Secrets("team-a").Create(context.Background(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-secret", Namespace: "team-a"},
+		Data:       map[string][]byte{"KEY": []byte("value")},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, SaveLabCluster(context.Background(), clientSet, path))
+
+	reloaded, err := LoadLabCluster(path)
+	assert.Nil(t, err)
+	secret, err := reloaded.CoreV1().Secrets("team-a").Get(context.Background(), "my-secret", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), secret.Data["KEY"])
+}
+
+func TestLabClusterKeepsWorkloads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl-tbac")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cluster.yaml")
+	content := `serviceAccounts:
+- metadata:
+    name: default
+    namespace: team-a
+deployments:
+- metadata:
+    name: my-app
+    namespace: team-a
+`
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	clientSet, err := LoadLabCluster(path)
+	assert.Nil(t, err)
+	assert.Nil(t, SaveLabCluster(context.Background(), clientSet, path))
+	reloaded, err := LoadLabCluster(path)
+	assert.Nil(t, err)
+	_, err = reloaded.CoreV1().ServiceAccounts("team-a").Get(context.Background(), "default", metav1.GetOptions{})
+	assert.Nil(t, err)
+	_, err = reloaded.AppsV1().Deployments("team-a").Get(context.Background(), "my-app", metav1.GetOptions{})
+	assert.Nil(t, err)
+}
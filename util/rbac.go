@@ -0,0 +1,76 @@
+package util
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultTeamNamespaceSelector selects the namespaces that belong to teams.
+const DefaultTeamNamespaceSelector = "tbac.bisnode.com/team"
+
+// TeamsFromRBAC returns the team namespaces where the current user may create
+// secrets, according to the RBAC rules of the cluster. Team namespaces are the
+// namespaces matching selector, except sandbox namespaces.
+func TeamsFromRBAC(ctx context.Context, clientSet kubernetes.Interface, selector string) ([]string, error) {
+	namespaces, err := clientSet.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list team namespaces")
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		teams = []string{}
+		errs  []error
+	)
+	for _, ns := range namespaces.Items {
+		if strings.HasSuffix(ns.Name, "-sandbox") {
+			continue
+		}
+		wg.Add(1)
+		go func(namespace string) {
+			defer wg.Done()
+			allowed, err := canCreateSecrets(ctx, clientSet, namespace)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				errs = append(errs, errors.Wrapf(err, "failed to review access to namespace %v", namespace))
+			case allowed:
+				teams = append(teams, namespace)
+			}
+		}(ns.Name)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	sort.Strings(teams)
+	return teams, nil
+}
+
+// canCreateSecrets asks the cluster whether the current user may create secrets in namespace.
+func canCreateSecrets(ctx context.Context, clientSet kubernetes.Interface, namespace string) (bool, error) {
+	review, err := clientSet.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "create",
+				Resource:  "secrets",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
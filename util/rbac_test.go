@@ -0,0 +1,41 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func teamNamespace(name string) *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{DefaultTeamNamespaceSelector: "true"}}}
+}
+
+func TestTeamsFromRBAC(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(
+		teamNamespace("team-a"),
+		teamNamespace("team-a-sandbox"),
+		teamNamespace("team-b"),
+		teamNamespace("team-c"),
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	)
+	// The user may create secrets in team-a, team-c and kube-system.
+	clientSet.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		assert.Equal(t, "create", attributes.Verb)
+		assert.Equal(t, "secrets", attributes.Resource)
+		review.Status.Allowed = attributes.Namespace != "team-b"
+		return true, review, nil
+	})
+
+	teams, err := TeamsFromRBAC(context.Background(), clientSet, DefaultTeamNamespaceSelector)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"team-a", "team-c"}, teams)
+}
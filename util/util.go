@@ -1,17 +1,15 @@
 package util
 
 import (
-	"fmt"
-	"log"
-	"os"
+	"context"
 	"regexp"
 
-	login "github.com/Bisnode/kubectl-login/util"
-
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
 	"github.com/pkg/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/transport"
 )
 
 // AssembleInputData is meant to parse data key value pairs
@@ -26,58 +24,78 @@ func AssembleInputData(data []string) map[string][]byte {
 	return dataMap
 }
 
-// CreateClientSet returns a kubernetes clientSet.
-func CreateClientSet(ctx *string) (*kubernetes.Clientset, error) {
-	configOverrides := &clientcmd.ConfigOverrides{}
-	if *ctx != "" {
-		configOverrides.CurrentContext = *ctx
-	}
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
-		&clientcmd.ConfigOverrides{CurrentContext: *ctx})
-	config, err := kubeConfig.ClientConfig()
+// CreateClientSet returns a kubernetes clientSet for the kube config selected by
+// the standard kubectl flags, such as --kubeconfig, --context and --as.
+// If wrap is not nil it wraps the transport of all requests, for example to log them.
+func CreateClientSet(flags *genericclioptions.ConfigFlags, wrap transport.WrapperFunc) (*kubernetes.Clientset, error) {
+	config, err := flags.ToRESTConfig()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load the kube config")
 	}
+	if wrap != nil {
+		config.Wrap(wrap)
+	}
 
 	clientSet, err := kubernetes.NewForConfig(config)
 	return clientSet, errors.Wrap(err, "cannot initialize a kubernetes client with loaded configuration")
 }
 
-// WhoAmI parses the jwt and looking for groups that it has.
-// It matches prefix using matchPrefix and trims away prefix using trimPrefix.
-func WhoAmI(matchPrefix, trimPrefix, ctx *string) (teams []string) {
-	clientCfg, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
+// CurrentContext returns the context given by --context, otherwise the
+// current-context of the kube config.
+func CurrentContext(flags *genericclioptions.ConfigFlags) (string, error) {
+	clientCfg, err := loadConfig(flags)
 	if err != nil {
-		log.Fatal("Failed to get default config")
+		return "", err
 	}
+	return clientCfg.CurrentContext, nil
+}
 
-	// Set current context if --context is passed.
-	if *ctx != "" && *ctx != clientCfg.CurrentContext {
-		clientCfg = login.LoadConfigFromContext(*ctx)
-		clientCfg.CurrentContext = *ctx
+// WhoAmI returns the teams of the current user, trying the named identity
+// sources in order until one can tell.
+// It matches prefix using matchPrefix and trims away prefix using trimPrefix.
+func WhoAmI(ctx context.Context, matchPrefix, trimPrefix *string, flags *genericclioptions.ConfigFlags, sources []string) (teams []string, err error) {
+	clientCfg, err := loadConfig(flags)
+	if err != nil {
+		return nil, err
 	}
-
-	if clientCfg.CurrentContext == "" {
-		fmt.Println("No current-context set - run 'kubectl login --init' to initialize context")
-		os.Exit(1)
+	currentContext, err := currentContext(clientCfg)
+	if err != nil {
+		return nil, err
 	}
-
-	rawToken := currentToken(clientCfg)
-	if rawToken == "" {
-		return []string{}
+	config, err := flags.ToRESTConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the kube config")
 	}
 
-	claims := login.JwtToIdentityClaims(rawToken)
+	id := &Identity{
+		Context:     currentContext,
+		Config:      config,
+		ClientSet:   func() (kubernetes.Interface, error) { return CreateClientSet(flags, nil) },
+		MatchPrefix: *matchPrefix,
+		TrimPrefix:  *trimPrefix,
+	}
+	return id.resolve(ctx, sources)
+}
 
-	return login.ExtractTeams(claims)
+// loadConfig returns the kube config selected by flags, the same one that
+// CreateClientSet connects with. The context given by --context is set as
+// its current-context.
+func loadConfig(flags *genericclioptions.ConfigFlags) (*api.Config, error) {
+	clientCfg, err := flags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the kube config")
+	}
+	if flags.Context != nil && *flags.Context != "" {
+		clientCfg.CurrentContext = *flags.Context
+	}
+	return &clientCfg, nil
 }
 
-func currentToken(clientCfg *api.Config) string {
+// currentContext returns the current-context of clientCfg, which is needed
+// to know who the user is.
+func currentContext(clientCfg *api.Config) (string, error) {
 	if clientCfg.CurrentContext == "" {
-		fmt.Println("No current-context set - run 'kubectl login --init' to initialize context")
-		os.Exit(1)
+		return "", tbacerrors.New(tbacerrors.NoContext, "no current-context set - run 'kubectl login --init' to initialize context")
 	}
-	// Note that absence of a token is not an error here but an empty string is returned
-	return login.ReadToken(clientCfg.CurrentContext)
+	return clientCfg.CurrentContext, nil
 }
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tbacerrors "github.com/Bisnode/kubectl-tbac/errors"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestCurrentContextMissing(t *testing.T) {
+	_, err := currentContext(api.NewConfig())
+	assert.True(t, tbacerrors.Is(err, tbacerrors.NoContext))
+}
+
+func TestWhoAmIWithoutContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl-tbac")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	kubeconfig := filepath.Join(dir, "config")
+	assert.Nil(t, ioutil.WriteFile(kubeconfig, []byte("apiVersion: v1\nkind: Config\n"), 0600))
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+	os.Setenv("KUBECONFIG", kubeconfig)
+
+	matchPrefix, trimPrefix := "sec-tbac-team-", "sec-tbac-"
+	teams, err := WhoAmI(context.Background(), &matchPrefix, &trimPrefix, genericclioptions.NewConfigFlags(true), DefaultIdentitySources)
+	assert.Nil(t, teams)
+	assert.Equal(t, 2, tbacerrors.ExitCode(err))
+}
+
+const twoContexts = `apiVersion: v1
+kind: Config
+clusters:
+- name: a
+  cluster: {server: "https://a.example.com"}
+- name: b
+  cluster: {server: "https://b.example.com"}
+contexts:
+- name: a
+  context: {cluster: a, user: u}
+- name: b
+  context: {cluster: b, user: u}
+users:
+- name: u
+  user: {token: abc}
+current-context: a
+`
+
+func TestKubeconfigAndContextFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl-tbac")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	kubeconfig := filepath.Join(dir, "config")
+	assert.Nil(t, ioutil.WriteFile(kubeconfig, []byte(twoContexts), 0600))
+
+	flags := genericclioptions.NewConfigFlags(true)
+	flags.KubeConfig = &kubeconfig
+	ctx, err := CurrentContext(flags)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", ctx)
+
+	other := "b"
+	flags = genericclioptions.NewConfigFlags(true)
+	flags.KubeConfig = &kubeconfig
+	flags.Context = &other
+	ctx, err = CurrentContext(flags)
+	assert.Nil(t, err)
+	assert.Equal(t, "b", ctx)
+
+	clientSet, err := CreateClientSet(flags, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "b.example.com", clientSet.CoreV1().RESTClient().Get().URL().Host)
+}
+
+func TestAssembleInputData(t *testing.T) {
+	data := AssembleInputData([]string{"USERNAME=foo", "URL=a=b"})
+	assert.Equal(t, []byte("foo"), data["USERNAME"])
+	assert.Equal(t, []byte("a=b"), data["URL"])
+}